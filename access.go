@@ -0,0 +1,81 @@
+package retroproxy
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// AccessList decides which clients may connect to a proxy from their IP address.
+type AccessList struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// ParseAccessList returns an AccessList from lists of IP addresses and CIDR prefixes. A client is allowed if its
+// address is not denied and, unless allow is empty, is allowed.
+func ParseAccessList(allow, deny []string) (*AccessList, error) {
+	l := &AccessList{}
+	var err error
+	l.allow, err = parsePrefixes(allow)
+	if err != nil {
+		return nil, err
+	}
+	l.deny, err = parsePrefixes(deny)
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		if strings.Contains(v, "/") {
+			prefix, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip address or prefix %q", v)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Allowed reports whether a client connecting from addr is allowed. A nil AccessList allows every client.
+func (l *AccessList) Allowed(addr net.Addr) bool {
+	if l == nil {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	ip, ok := netip.AddrFromSlice(tcpAddr.IP)
+	if !ok {
+		return false
+	}
+	ip = ip.Unmap()
+
+	for _, prefix := range l.deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(l.allow) == 0 {
+		return true
+	}
+	for _, prefix := range l.allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
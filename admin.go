@@ -0,0 +1,265 @@
+package retroproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// SessionLister is implemented by proxies that can list the sessions they handle.
+type SessionLister interface {
+	Sessions() []SessionInfo
+}
+
+// Kicker is implemented by proxies that can disconnect the sessions they handle.
+type Kicker interface {
+	// KickSession disconnects the session identified by id, and reports whether it was found.
+	KickSession(id string) bool
+	// KickAccount disconnects the sessions of account, and returns how many there were.
+	KickAccount(account string) int
+}
+
+// Drainer is implemented by proxies that can stop taking new sessions while letting the current ones carry on.
+type Drainer interface {
+	SetDraining(v bool)
+	Draining() bool
+}
+
+// AdminHandler serves the admin API of the proxies over HTTP, so that operators can see who is connected, disconnect
+// them and drain the proxies before a maintenance.
+type AdminHandler struct {
+	logger  *zap.Logger
+	storer  ContextStorer
+	proxies map[string]SessionLister
+	mux     *http.ServeMux
+	token   string
+}
+
+// NewAdminHandler returns an AdminHandler for proxies, keyed by name, and the tickets of storer.
+func NewAdminHandler(proxies map[string]SessionLister, storer ContextStorer, logger *zap.Logger) (*AdminHandler, error) {
+	if storer == nil {
+		return nil, errors.New("storer is nil")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	h := &AdminHandler{
+		logger:  logger,
+		storer:  storer,
+		proxies: proxies,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/sessions", h.handleSessions)
+	h.mux.HandleFunc("/sessions/", h.handleSessionKick)
+	h.mux.HandleFunc("/accounts/", h.handleAccountKick)
+	h.mux.HandleFunc("/tickets", h.handleTickets)
+	h.mux.HandleFunc("/drain", h.handleDrain)
+	return h, nil
+}
+
+// SetToken sets the bearer token the requests must carry, or lets any request through if token is empty. Anyone who
+// can reach the API can kick sessions without one, so it should only listen on a loopback address then.
+func (h *AdminHandler) SetToken(token string) {
+	h.token = token
+}
+
+func (h *AdminHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !authorized(req, h.token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	h.mux.ServeHTTP(w, req)
+}
+
+type adminSession struct {
+	Proxy string `json:"proxy"`
+	SessionInfo
+}
+
+func (h *AdminHandler) handleSessions(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessions := []adminSession{}
+	for name, p := range h.proxies {
+		for _, info := range p.Sessions() {
+			sessions = append(sessions, adminSession{Proxy: name, SessionInfo: info})
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt)
+	})
+	h.writeJSON(w, sessions)
+}
+
+// handleSessionKick handles POST /sessions/{id}/kick.
+func (h *AdminHandler) handleSessionKick(w http.ResponseWriter, req *http.Request) {
+	id, ok := kickTarget(req.URL.Path, "/sessions/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	for name, p := range h.proxies {
+		k, ok := p.(Kicker)
+		if !ok {
+			continue
+		}
+		if k.KickSession(id) {
+			h.logger.Info("session kicked",
+				zap.String("proxy", name),
+				zap.String("session_id", id),
+			)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	http.NotFound(w, req)
+}
+
+// handleAccountKick handles POST /accounts/{account}/kick.
+func (h *AdminHandler) handleAccountKick(w http.ResponseWriter, req *http.Request) {
+	account, ok := kickTarget(req.URL.Path, "/accounts/")
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	n := 0
+	for _, p := range h.proxies {
+		k, ok := p.(Kicker)
+		if !ok {
+			continue
+		}
+		n += k.KickAccount(account)
+	}
+	h.logger.Info("account kicked",
+		zap.String("account", account),
+		zap.Int("sessions", n),
+	)
+	h.writeJSON(w, struct {
+		Kicked int `json:"kicked"`
+	}{n})
+}
+
+// kickTarget returns the non-empty segment between prefix and "/kick" in path.
+func kickTarget(path, prefix string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, prefix)
+	if !ok {
+		return "", false
+	}
+	target, ok := strings.CutSuffix(rest, "/kick")
+	if !ok || target == "" || strings.Contains(target, "/") {
+		return "", false
+	}
+	return target, true
+}
+
+// handleDrain reports whether the proxies are draining on GET, and starts or stops draining them on PUT or DELETE.
+func (h *AdminHandler) handleDrain(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodDelete:
+		for _, p := range h.proxies {
+			if d, ok := p.(Drainer); ok {
+				d.SetDraining(req.Method == http.MethodPut)
+			}
+		}
+	default:
+		w.Header().Set("Allow", strings.Join([]string{http.MethodGet, http.MethodPut, http.MethodDelete}, ", "))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	draining := make(map[string]bool)
+	for name, p := range h.proxies {
+		if d, ok := p.(Drainer); ok {
+			draining[name] = d.Draining()
+		}
+	}
+	h.writeJSON(w, draining)
+}
+
+// adminTicket is a ticket as listed by the admin API. It leaves out the ticket id and the original ticket, which
+// would let anyone reading the list take over the game session.
+type adminTicket struct {
+	Account   string    `json:"account,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	ServerId  int       `json:"server_id,omitempty"`
+	Realm     string    `json:"realm,omitempty"`
+	SourceIP  string    `json:"source_ip,omitempty"`
+	Host      string    `json:"host"`
+	Port      string    `json:"port"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (h *AdminHandler) handleTickets(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	tickets, err := ListTickets(req.Context(), h.storer)
+	if err != nil {
+		if errors.Is(err, ErrListNotSupported) {
+			http.Error(w, err.Error(), http.StatusNotImplemented)
+		} else {
+			h.serverError(w, err)
+		}
+		return
+	}
+
+	list := []adminTicket{}
+	for _, t := range tickets {
+		list = append(list, adminTicket{
+			Account:   t.Account,
+			ClientIP:  t.ClientIP,
+			ServerId:  t.ServerId,
+			Realm:     t.Realm,
+			SourceIP:  t.SourceIP,
+			Host:      t.Host,
+			Port:      t.Port,
+			IssuedAt:  t.IssuedAt,
+			ExpiresAt: t.ExpiresAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].IssuedAt.Before(list[j].IssuedAt)
+	})
+	h.writeJSON(w, list)
+}
+
+func (h *AdminHandler) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		h.logger.Debug("could not write response", zap.Error(err))
+	}
+}
+
+func (h *AdminHandler) serverError(w http.ResponseWriter, err error) {
+	h.logger.Error("admin api error", zap.Error(err))
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
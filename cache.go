@@ -6,12 +6,17 @@ import (
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/kralamoure/retroproxy/metrics"
 )
 
 // Cache is an implementation of Storer for an in-memory cache.
 type Cache struct {
 	logger  *zap.Logger
 	tickets map[string]Ticket
+	timers  map[string]*time.Timer
+	ttl     time.Duration
+	metrics *metrics.Metrics
 	mu      sync.Mutex
 }
 
@@ -19,7 +24,15 @@ func NewCache(logger *zap.Logger) *Cache {
 	if logger == nil {
 		logger = zap.NewNop()
 	}
-	return &Cache{logger: logger}
+	return &Cache{logger: logger, ttl: DefaultTicketTTL}
+}
+
+// SetTicketTTL sets the lifetime of the tickets set from now on without their own expiry, counted from when they were
+// issued.
+func (r *Cache) SetTicketTTL(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = d
 }
 
 func (r *Cache) SetTicket(id string, t Ticket) {
@@ -27,10 +40,21 @@ func (r *Cache) SetTicket(id string, t Ticket) {
 	defer r.mu.Unlock()
 	if r.tickets == nil {
 		r.tickets = make(map[string]Ticket)
+		r.timers = make(map[string]*time.Timer)
+	}
+	if timer, ok := r.timers[id]; ok {
+		timer.Stop()
+		delete(r.timers, id)
 	}
+	t = t.withExpiry(r.ttl)
 	r.tickets[id] = t
+	if !t.ExpiresAt.IsZero() {
+		r.timers[id] = time.AfterFunc(time.Until(t.ExpiresAt), func() {
+			r.expire(id, t.ExpiresAt)
+		})
+	}
 	r.logger.Debug("ticket set",
-		zap.String("ticket_id", id),
+		zap.String("ticket_id", redactTicketId(id)),
 		zap.String("ticket", fmt.Sprintf("%+v", t)),
 	)
 }
@@ -40,25 +64,56 @@ func (r *Cache) UseTicket(id string) (Ticket, bool) {
 	defer r.mu.Unlock()
 	t, ok := r.tickets[id]
 	if ok {
-		delete(r.tickets, id)
+		r.delete(id)
+		if t.Expired(time.Now()) {
+			return Ticket{}, false
+		}
 		r.logger.Debug("ticket used",
-			zap.String("ticket_id", id),
+			zap.String("ticket_id", redactTicketId(id)),
 		)
 	}
 	return t, ok
 }
 
-func (r *Cache) DeleteOldTickets(maxDur time.Duration) {
+func (r *Cache) Tickets() map[string]Ticket {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	now := time.Now()
-	for id := range r.tickets {
-		deadline := r.tickets[id].IssuedAt.Add(maxDur)
-		if now.After(deadline) {
-			delete(r.tickets, id)
-			r.logger.Debug("old ticket deleted",
-				zap.String("ticket_id", id),
-			)
+	tickets := make(map[string]Ticket, len(r.tickets))
+	for id, t := range r.tickets {
+		if !t.Expired(now) {
+			tickets[id] = t
 		}
 	}
+	return tickets
+}
+
+// expire deletes the ticket identified by id if it's still the one that expires at expiresAt.
+func (r *Cache) expire(id string, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tickets[id]
+	if !ok || !t.ExpiresAt.Equal(expiresAt) {
+		return
+	}
+	r.delete(id)
+	r.metrics.TicketExpired()
+	r.logger.Debug("expired ticket deleted",
+		zap.String("ticket_id", redactTicketId(id)),
+	)
+}
+
+// SetMetrics sets the metrics counting the expired tickets, or disables them if m is nil.
+func (r *Cache) SetMetrics(m *metrics.Metrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = m
+}
+
+func (r *Cache) delete(id string) {
+	delete(r.tickets, id)
+	if timer, ok := r.timers[id]; ok {
+		timer.Stop()
+		delete(r.timers, id)
+	}
 }
@@ -0,0 +1,39 @@
+// Package capture records the packets of proxy sessions as JSON lines, one Record per line, so that sessions can be
+// inspected and replayed later.
+package capture
+
+import (
+	"time"
+)
+
+// Direction is the way a packet went through a proxy.
+type Direction string
+
+const (
+	// FromClient is a packet received by the proxy from the client.
+	FromClient Direction = "from_client"
+	// ToServer is a packet sent by the proxy to the server.
+	ToServer Direction = "to_server"
+	// FromServer is a packet received by the proxy from the server.
+	FromServer Direction = "from_server"
+	// ToClient is a packet sent by the proxy to the client.
+	ToClient Direction = "to_client"
+)
+
+// Record is a packet of a session.
+type Record struct {
+	Time        time.Time `json:"time"`
+	Proxy       string    `json:"proxy"`
+	SessionId   string    `json:"session_id"`
+	Account     string    `json:"account,omitempty"`
+	Direction   Direction `json:"direction"`
+	MessageName string    `json:"message_name,omitempty"`
+	Packet      string    `json:"packet"`
+}
+
+// Recorder records the packets of sessions. Its methods may be called concurrently by different sessions.
+type Recorder interface {
+	Record(rec Record) error
+	// EndSession is called once the session identified by sessionId ended.
+	EndSession(sessionId string) error
+}
@@ -0,0 +1,71 @@
+package capture
+
+//go:generate go run ./internal/genmsgs
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/kralamoure/retroproto"
+
+	"github.com/kralamoure/retroproxy"
+)
+
+// ErrUnknownMsg is returned when decoding a packet of an unknown message type.
+var ErrUnknownMsg = errors.New("unknown message")
+
+// Client reports whether the packet of the record was sent by the client.
+func (r Record) Client() bool {
+	return r.Direction == FromClient || r.Direction == ToServer
+}
+
+// Decode deserializes the packet of the record into the retroproto message it carries, a retroproto.MsgCli if it was
+// sent by the client or a retroproto.MsgSvr otherwise.
+func (r Record) Decode() (any, error) {
+	if r.Client() {
+		pkt := unwrapCliPkt(r.Packet)
+		id, ok := retroproxy.CliMsgIdByPkt(pkt)
+		if !ok {
+			return nil, ErrUnknownMsg
+		}
+		newMsg, ok := newMsgCli[id]
+		if !ok {
+			return nil, ErrUnknownMsg
+		}
+		msg := newMsg()
+		err := msg.Deserialize(strings.TrimPrefix(pkt, string(id)))
+		if err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	id, ok := retroproto.MsgSvrIdByPkt(r.Packet)
+	if !ok {
+		return nil, ErrUnknownMsg
+	}
+	newMsg, ok := newMsgSvr[id]
+	if !ok {
+		return nil, ErrUnknownMsg
+	}
+	msg := newMsg()
+	err := msg.Deserialize(strings.TrimPrefix(r.Packet, string(id)))
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// unwrapCliPkt returns pkt without the "ù"-delimited prefix the game client puts in front of some types of packet.
+func unwrapCliPkt(pkt string) string {
+	const unknownToken = "ù"
+	if !strings.HasPrefix(pkt, unknownToken) {
+		return pkt
+	}
+	const index = 2
+	substrings := strings.SplitN(pkt, unknownToken, index+1)
+	if len(substrings) != index+1 {
+		return pkt
+	}
+	return substrings[index]
+}
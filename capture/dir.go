@@ -0,0 +1,74 @@
+package capture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Dir is a Recorder that writes each session to its own file in a directory.
+type Dir struct {
+	path  string
+	files map[string]*os.File
+	mu    sync.Mutex
+}
+
+func NewDir(path string) (*Dir, error) {
+	err := os.MkdirAll(path, 0o750)
+	if err != nil {
+		return nil, err
+	}
+	return &Dir{
+		path:  path,
+		files: make(map[string]*os.File),
+	}, nil
+}
+
+func (r *Dir) Record(rec Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.files[rec.SessionId]
+	if !ok {
+		name := fmt.Sprintf("%s-%s-%s.jsonl", rec.Time.UTC().Format("20060102T150405Z"), rec.Proxy, rec.SessionId)
+		f, err = os.OpenFile(filepath.Join(r.path, name), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+		if err != nil {
+			return err
+		}
+		r.files[rec.SessionId] = f
+	}
+
+	_, err = f.Write(append(b, '\n'))
+	return err
+}
+
+func (r *Dir) EndSession(sessionId string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.files[sessionId]
+	if !ok {
+		return nil
+	}
+	delete(r.files, sessionId)
+	return f.Close()
+}
+
+// Close closes the files of the sessions that didn't end yet.
+func (r *Dir) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var errs []error
+	for id, f := range r.files {
+		errs = append(errs, f.Close())
+		delete(r.files, id)
+	}
+	return errors.Join(errs...)
+}
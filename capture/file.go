@@ -0,0 +1,92 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// File is a Recorder that writes every session to a single file. Once the file reaches its maximum size, it's renamed
+// with the time of the rotation as suffix and a new one is started.
+type File struct {
+	path    string
+	maxSize int64
+	f       *os.File
+	size    int64
+	mu      sync.Mutex
+}
+
+// OpenFile returns a File writing to path. A maxSize of zero or less disables the rotation.
+func OpenFile(path string, maxSize int64) (*File, error) {
+	r := &File{
+		path:    path,
+		maxSize: maxSize,
+	}
+	err := r.open()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *File) Record(rec Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.maxSize > 0 && r.size > 0 && r.size+int64(len(b)) > r.maxSize {
+		err := r.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := r.f.Write(b)
+	r.size += int64(n)
+	return err
+}
+
+func (r *File) EndSession(sessionId string) error {
+	return nil
+}
+
+func (r *File) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+func (r *File) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f = f
+	r.size = fi.Size()
+	return nil
+}
+
+func (r *File) rotate() error {
+	err := r.f.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(r.path, fmt.Sprintf("%s.%s", r.path, time.Now().UTC().Format("20060102T150405.000000000Z")))
+	openErr := r.open()
+	if err != nil {
+		return err
+	}
+	return openErr
+}
@@ -0,0 +1,115 @@
+// Command genmsgs generates the tables of the message types of retroproto used to decode captured packets.
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const modulePath = "github.com/kralamoure/retroproto"
+
+var tmpl = template.Must(template.New("").Parse(`// Code generated by genmsgs; DO NOT EDIT.
+
+package capture
+
+import (
+	"github.com/kralamoure/retroproto"
+	"github.com/kralamoure/retroproto/msgcli"
+	"github.com/kralamoure/retroproto/msgsvr"
+)
+
+var newMsgSvr = map[retroproto.MsgSvrId]func() retroproto.MsgSvr{
+{{- range .Svr}}
+	retroproto.{{.}}: func() retroproto.MsgSvr { return &msgsvr.{{.}}{} },
+{{- end}}
+}
+
+var newMsgCli = map[retroproto.MsgCliId]func() retroproto.MsgCli{
+{{- range .Cli}}
+	retroproto.{{.}}: func() retroproto.MsgCli { return &msgcli.{{.}}{} },
+{{- end}}
+}
+`))
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", modulePath).Output()
+	if err != nil {
+		log.Fatalf("could not locate %s: %s", modulePath, err)
+	}
+	dir := strings.TrimSpace(string(out))
+
+	svr, err := msgTypes(filepath.Join(dir, "msgsvr"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cli, err := msgTypes(filepath.Join(dir, "msgcli"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct{ Svr, Cli []string }{svr, cli})
+	if err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = os.WriteFile("msgs_gen.go", src, 0644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// msgTypes returns the names of the types of the package in dir that have both a MessageId method and a Deserialize
+// method with a pointer receiver, which are the types implementing retroproto.MsgSvr or retroproto.MsgCli.
+func msgTypes(dir string) ([]string, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	hasId := make(map[string]bool)
+	hasDeserialize := make(map[string]bool)
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+					continue
+				}
+				switch typ := fn.Recv.List[0].Type.(type) {
+				case *ast.Ident:
+					if fn.Name.Name == "MessageId" {
+						hasId[typ.Name] = true
+					}
+				case *ast.StarExpr:
+					ident, ok := typ.X.(*ast.Ident)
+					if ok && fn.Name.Name == "Deserialize" {
+						hasDeserialize[ident.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	var names []string
+	for name := range hasId {
+		if hasDeserialize[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
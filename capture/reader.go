@@ -0,0 +1,70 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// maxRecordSize is the maximum size of a line of a capture.
+const maxRecordSize = 1 << 20
+
+// Reader reads the records of a capture.
+type Reader struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func NewReader(r io.Reader) *Reader {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64<<10), maxRecordSize)
+	return &Reader{sc: sc}
+}
+
+// Next returns the next record of the capture, or io.EOF once there are no more records.
+func (r *Reader) Next() (Record, error) {
+	for r.sc.Scan() {
+		r.line++
+		if len(r.sc.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		err := json.Unmarshal(r.sc.Bytes(), &rec)
+		if err != nil {
+			return Record{}, fmt.Errorf("invalid record at line %d: %w", r.line, err)
+		}
+		return rec, nil
+	}
+	err := r.sc.Err()
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{}, io.EOF
+}
+
+// ReadSession returns the records of the session identified by sessionId, or of the first session of the capture if
+// sessionId is empty.
+func ReadSession(r io.Reader, sessionId string) ([]Record, error) {
+	rd := NewReader(r)
+	var recs []Record
+	for {
+		rec, err := rd.Next()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if sessionId == "" {
+			sessionId = rec.SessionId
+		}
+		if rec.SessionId == sessionId {
+			recs = append(recs, rec)
+		}
+	}
+	if len(recs) == 0 {
+		return nil, fmt.Errorf("session not found: %s", sessionId)
+	}
+	return recs, nil
+}
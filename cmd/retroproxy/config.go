@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of the environment variables that set flags, e.g. RETROPROXY_TICKET_TTL for --ticket-ttl.
+const envPrefix = "RETROPROXY_"
+
+// configFlag is the flag giving the path of the config file, which can't be set from the file itself.
+const configFlag = "config"
+
+// applyConfigFile sets the flags that were not set on the command line from the config file at path, and returns the
+// realms it lists. Its other keys are the long names of the flags.
+func applyConfigFile(flags *pflag.FlagSet, path string) ([]realmConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]any
+	err = yaml.Unmarshal(b, &values)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	var realms []realmConfig
+	if _, ok := values[realmsKey]; ok {
+		delete(values, realmsKey)
+		realms, err = decodeRealms(b)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file: key %q: %w", realmsKey, err)
+		}
+	}
+
+	for key, v := range values {
+		f := flags.Lookup(key)
+		if f == nil || key == configFlag {
+			return nil, fmt.Errorf("invalid config file: unknown key %q", key)
+		}
+		if f.Changed {
+			continue
+		}
+		var s string
+		switch v := v.(type) {
+		case nil:
+		case []any:
+			values := make([]string, len(v))
+			for i := range v {
+				values[i] = fmt.Sprint(v[i])
+			}
+			s = strings.Join(values, ",")
+		case map[string]any:
+			return nil, fmt.Errorf("invalid config file: key %q: unexpected mapping", key)
+		default:
+			s = fmt.Sprint(v)
+		}
+		err := f.Value.Set(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid config file: key %q: %w", key, err)
+		}
+	}
+	return realms, nil
+}
+
+// decodeRealms decodes the realms of the config file b, which must only have the keys of realmConfig.
+func decodeRealms(b []byte) ([]realmConfig, error) {
+	var file struct {
+		Realms yaml.Node `yaml:"realms"`
+	}
+	err := yaml.Unmarshal(b, &file)
+	if err != nil {
+		return nil, err
+	}
+	// Node.Decode can't reject unknown keys, so the realms are decoded again on their own.
+	realmsYAML, err := yaml.Marshal(&file.Realms)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(realmsYAML))
+	dec.KnownFields(true)
+	var realms []realmConfig
+	err = dec.Decode(&realms)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return realms, nil
+}
+
+// applyEnv sets the flags from the environment variables named after them, which take precedence over both the
+// command line and the config file.
+func applyEnv(flags *pflag.FlagSet) error {
+	var errs []error
+	flags.VisitAll(func(f *pflag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		var err error
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			// Setting a slice flag appends to it, which would keep the values given on the command line.
+			values := []string{}
+			if v != "" {
+				values, err = csv.NewReader(strings.NewReader(v)).Read()
+			}
+			if err == nil {
+				err = slice.Replace(values)
+			}
+			f.Changed = true
+		} else {
+			err = flags.Set(f.Name, v)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid environment variable %s: %w", name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
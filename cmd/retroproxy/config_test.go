@@ -0,0 +1,66 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  *string
+		want []string
+	}{
+		{
+			name: "command line only",
+			args: []string{"--deny", "192.0.2.1"},
+			want: []string{"192.0.2.1"},
+		},
+		{
+			name: "environment only",
+			env:  ptr("198.51.100.0/24,203.0.113.7"),
+			want: []string{"198.51.100.0/24", "203.0.113.7"},
+		},
+		{
+			name: "environment replacing the command line",
+			args: []string{"--deny", "192.0.2.1", "--deny", "192.0.2.2"},
+			env:  ptr("203.0.113.7"),
+			want: []string{"203.0.113.7"},
+		},
+		{
+			name: "empty environment variable",
+			args: []string{"--deny", "192.0.2.1"},
+			env:  ptr(""),
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var deny []string
+			flags := pflag.NewFlagSet("retroproxy", pflag.ContinueOnError)
+			flags.StringSliceVar(&deny, "deny", nil, "")
+			err := flags.Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if tt.env != nil {
+				t.Setenv("RETROPROXY_DENY", *tt.env)
+			}
+
+			err = applyEnv(flags)
+			if err != nil {
+				t.Fatalf("applyEnv() error = %v", err)
+			}
+			if !reflect.DeepEqual(deny, tt.want) {
+				t.Fatalf("applyEnv() set --deny to %q, want %q", deny, tt.want)
+			}
+		})
+	}
+}
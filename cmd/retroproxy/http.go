@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// serveHTTP serves handler on addr of network until ctx is done. The logs of the listener are named after name.
+func serveHTTP(ctx context.Context, name, network, addr string, handler http.Handler) error {
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	logger.Info(name+" listening",
+		zap.String("address", ln.Addr().String()),
+	)
+	defer logger.Info(name+" stopped listening",
+		zap.String("address", ln.Addr().String()),
+	)
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			srv.Close()
+		case <-done:
+		}
+	}()
+
+	err = srv.Serve(ln)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/kralamoure/retroproxy/capture"
+)
+
+var (
+	inspectSession    string
+	inspectDirections []string
+	inspectMessages   []string
+	inspectSince      string
+	inspectUntil      string
+	inspectMatch      string
+	inspectJSON       bool
+)
+
+// inspectFilter selects the records printed by the inspect subcommand.
+type inspectFilter struct {
+	sessionId  string
+	directions map[capture.Direction]bool
+	messages   map[string]bool
+	since      time.Time
+	until      time.Time
+	match      *regexp.Regexp
+}
+
+func (f inspectFilter) keep(rec capture.Record) bool {
+	if f.sessionId != "" && rec.SessionId != f.sessionId {
+		return false
+	}
+	if len(f.directions) > 0 && !f.directions[rec.Direction] {
+		return false
+	}
+	if len(f.messages) > 0 && !f.messages[rec.MessageName] {
+		return false
+	}
+	if !f.since.IsZero() && rec.Time.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && rec.Time.After(f.until) {
+		return false
+	}
+	if f.match != nil && !f.match.MatchString(rec.Packet) {
+		return false
+	}
+	return true
+}
+
+// inspectedRecord is a record printed by the inspect subcommand along with the message decoded from its packet.
+type inspectedRecord struct {
+	capture.Record
+	Message     any    `json:"message,omitempty"`
+	DecodeError string `json:"decode_error,omitempty"`
+}
+
+// runInspect prints the timeline of the packets of captures, decoded and filtered.
+func runInspect(args []string) int {
+	flags, filter, err := loadInspectVars(args)
+	if err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		log.Println(err)
+		return 2
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"-"}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	for _, path := range paths {
+		err := inspectCapture(path, filter, func(rec inspectedRecord) error {
+			if inspectJSON {
+				return enc.Encode(rec)
+			}
+			printInspectedRecord(rec)
+			return nil
+		})
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func inspectCapture(path string, filter inspectFilter, print func(rec inspectedRecord) error) error {
+	var r io.Reader
+	if path == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	rd := capture.NewReader(r)
+	for {
+		rec, err := rd.Next()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if !filter.keep(rec) {
+			continue
+		}
+
+		inspected := inspectedRecord{Record: rec}
+		msg, err := rec.Decode()
+		if err != nil {
+			inspected.DecodeError = err.Error()
+		} else {
+			inspected.Message = msg
+		}
+		err = print(inspected)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func printInspectedRecord(rec inspectedRecord) {
+	fmt.Printf("%s %s %s %-11s %s %q\n",
+		rec.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		rec.Proxy,
+		rec.SessionId,
+		rec.Direction,
+		rec.MessageName,
+		rec.Packet,
+	)
+	if rec.Message != nil {
+		fmt.Printf("\t%+v\n", rec.Message)
+	} else if rec.DecodeError != "" {
+		fmt.Printf("\tcould not decode: %s\n", rec.DecodeError)
+	}
+}
+
+func loadInspectVars(args []string) (*pflag.FlagSet, inspectFilter, error) {
+	flags := pflag.NewFlagSet("retroproxy inspect", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: retroproxy inspect [flags] [CAPTURE_FILE...]")
+		flags.PrintDefaults()
+	}
+	flags.StringVar(&inspectSession, "session", "", "Only show the packets of this session id")
+	flags.StringSliceVar(&inspectDirections, "direction", nil,
+		"Only show the packets going in these directions (from_client, to_server, from_server, to_client)")
+	flags.StringSliceVar(&inspectMessages, "message", nil, "Only show the packets of these message names")
+	flags.StringVar(&inspectSince, "since", "", "Only show the packets recorded from this RFC 3339 time")
+	flags.StringVar(&inspectUntil, "until", "", "Only show the packets recorded until this RFC 3339 time")
+	flags.StringVar(&inspectMatch, "match", "", "Only show the packets matching this regular expression")
+	flags.BoolVar(&inspectJSON, "json", false, "Print the packets as JSON lines")
+	flags.SortFlags = false
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return nil, inspectFilter{}, err
+	}
+
+	filter := inspectFilter{sessionId: inspectSession}
+	if len(inspectDirections) > 0 {
+		filter.directions = make(map[capture.Direction]bool)
+		for _, v := range inspectDirections {
+			dir := capture.Direction(v)
+			switch dir {
+			case capture.FromClient, capture.ToServer, capture.FromServer, capture.ToClient:
+			default:
+				return nil, inspectFilter{}, fmt.Errorf("invalid direction: %s", v)
+			}
+			filter.directions[dir] = true
+		}
+	}
+	if len(inspectMessages) > 0 {
+		filter.messages = make(map[string]bool)
+		for _, v := range inspectMessages {
+			filter.messages[v] = true
+		}
+	}
+	if inspectSince != "" {
+		filter.since, err = time.Parse(time.RFC3339, inspectSince)
+		if err != nil {
+			return nil, inspectFilter{}, fmt.Errorf("invalid since time: %w", err)
+		}
+	}
+	if inspectUntil != "" {
+		filter.until, err = time.Parse(time.RFC3339, inspectUntil)
+		if err != nil {
+			return nil, inspectFilter{}, fmt.Errorf("invalid until time: %w", err)
+		}
+	}
+	if inspectMatch != "" {
+		filter.match, err = regexp.Compile(inspectMatch)
+		if err != nil {
+			return nil, inspectFilter{}, fmt.Errorf("invalid match expression: %w", err)
+		}
+	}
+	return flags, filter, nil
+}
@@ -5,39 +5,110 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"net/netip"
 	"os"
 	"os/signal"
 	"runtime/trace"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/pflag"
 
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 
 	"github.com/kralamoure/retroproxy"
-	"github.com/kralamoure/retroproxy/game"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/codec"
 	"github.com/kralamoure/retroproxy/login"
+	"github.com/kralamoure/retroproxy/metrics"
 )
 
-var (
+// settings are the values of the flags, set from the command line, the environment and the config file.
+type settings struct {
 	debug               bool
-	loginServerAddr     string
+	loginServerAddrs    []string
+	balancing           string
+	healthInterval      time.Duration
 	loginProxyAddr      string
 	gameProxyAddr       string
 	gameProxyPublicAddr string
 	forceAdmin          bool
-)
+	rewritePort         bool
+	rewriteIdentity     bool
+	ticketFile          string
+	storeURL            string
+	storeToken          string
+	ticketKey           string
+	ticketDur           time.Duration
+	bindTickets         bool
+	logSecrets          bool
+	captureDir          string
+	captureFile         string
+	captureMaxSize      int64
+	adminAddr           string
+	adminToken          string
+	metricsAddr         string
+	gracePeriod         time.Duration
+	network             string
+	dialTimeout         time.Duration
+	maxPktSize          int
+	dnsTTL              time.Duration
+	outboundProxy       string
+	accountProxies      []string
+	sourceAddrs         []string
+	accountSources      []string
+	configFile          string
+	logLevelName        string
+	allowClients        []string
+	denyClients         []string
+	realmName           string
+
+	// extraRealms are the realms of the config file.
+	extraRealms []realmConfig
+}
 
-var logger *zap.Logger
+var (
+	logger   *zap.Logger
+	logLevel zap.AtomicLevel
+
+	// vars are the settings in use, replaced on reload once the new ones are valid.
+	vars settings
+
+	// forceAdminEnabled, rewritePortEnabled and rewriteIdentityEnabled hold the settings enabling the built-in packet
+	// handlers, which can change on reload.
+	forceAdminEnabled      atomic.Bool
+	rewritePortEnabled     atomic.Bool
+	rewriteIdentityEnabled atomic.Bool
+)
 
 func main() {
 	os.Exit(run())
 }
 
 func run() int {
-	err := loadVars()
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "store":
+			return runStore(os.Args[1:])
+		case "inspect":
+			return runInspect(os.Args[1:])
+		case "replay":
+			return runReplay(os.Args[1:])
+		}
+	}
+
+	var err error
+	vars, err = loadVars()
 	if err != nil {
 		if errors.Is(err, pflag.ErrHelp) {
 			return 0
@@ -46,7 +117,7 @@ func run() int {
 		return 2
 	}
 
-	if debug {
+	if vars.debug {
 		traceFile, err := os.Create("trace.out")
 		if err != nil {
 			log.Println(err)
@@ -61,102 +132,719 @@ func run() int {
 		defer trace.Stop()
 	}
 
-	if debug {
-		tmp, err := zap.NewDevelopment()
-		if err != nil {
-			log.Println(err)
-			return 1
-		}
-		logger = tmp
-	} else {
-		tmp, err := zap.NewProduction()
-		if err != nil {
-			log.Println(err)
-			return 1
-		}
-		logger = tmp
+	err = loadLogger(vars.debug)
+	if err != nil {
+		log.Println(err)
+		return 1
 	}
 	defer logger.Sync()
 
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	errCh := make(chan error)
 
-	storer := retroproxy.NewCache(logger.Named("cache"))
+	var m *metrics.Metrics
+	if vars.metricsAddr != "" {
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+		m, err = metrics.New(reg)
+		if err != nil {
+			logger.Error("could not make metrics", zap.Error(err))
+			return 1
+		}
+		handler := http.NewServeMux()
+		handler.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+		network, addr := vars.network, vars.metricsAddr
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := serveHTTP(ctx, "metrics", network, addr, handler)
+			if err != nil {
+				select {
+				case errCh <- fmt.Errorf("error while serving metrics: %w", err):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	var storer retroproxy.ContextStorer
+	if vars.ticketKey != "" {
+		sealer, err := retroproxy.NewSealer([]byte(vars.ticketKey), logger.Named("sealer"))
+		if err != nil {
+			logger.Error("could not make ticket sealer", zap.Error(err))
+			return 1
+		}
+		storer = sealer
+	} else if vars.storeURL != "" {
+		remote, err := retroproxy.NewRemote(vars.storeURL, logger.Named("remote"))
+		if err != nil {
+			logger.Error("could not make remote ticket store", zap.Error(err))
+			return 1
+		}
+		remote.SetToken(vars.storeToken)
+		storer = remote
+	} else if vars.ticketFile != "" {
+		file, err := retroproxy.OpenFile(vars.ticketFile, logger.Named("file"))
+		if err != nil {
+			logger.Error("could not open ticket file", zap.Error(err))
+			return 1
+		}
+		defer file.Close()
+		file.SetTicketTTL(vars.ticketDur)
+		file.SetMetrics(m)
+		storer = file
+	} else {
+		cache := retroproxy.NewCache(logger.Named("cache"))
+		cache.SetTicketTTL(vars.ticketDur)
+		cache.SetMetrics(m)
+		storer = retroproxy.AdaptStorer(cache)
+	}
 
-	loginPx, err := login.NewProxy(
-		loginProxyAddr,
-		loginServerAddr,
-		gameProxyPublicAddr,
-		storer,
-		forceAdmin,
-		logger.Named("login"),
-	)
+	var recorder capture.Recorder
+	if vars.captureDir != "" {
+		dir, err := capture.NewDir(vars.captureDir)
+		if err != nil {
+			logger.Error("could not make capture directory", zap.Error(err))
+			return 1
+		}
+		defer dir.Close()
+		recorder = dir
+	} else if vars.captureFile != "" {
+		file, err := capture.OpenFile(vars.captureFile, vars.captureMaxSize<<20)
+		if err != nil {
+			logger.Error("could not open capture file", zap.Error(err))
+			return 1
+		}
+		defer file.Close()
+		recorder = file
+	}
+
+	proxies := make(map[string]retroproxy.SessionLister)
+	var shutdowners []shutdowner
+
+	var realms []*realm
+	for _, cfg := range vars.realmConfigs() {
+		r, err := newRealm(cfg, storer, recorder, m)
+		if err != nil {
+			logger.Error("could not make realm",
+				zap.Error(err),
+				zap.String("realm", cfg.Name),
+			)
+			return 1
+		}
+		if r.login != nil {
+			proxies[proxyName("login", r.name)] = r.login
+			shutdowners = append(shutdowners, r.login)
+		}
+		if r.game != nil {
+			proxies[proxyName("game", r.name)] = r.game
+			shutdowners = append(shutdowners, r.game)
+		}
+		realms = append(realms, r)
+	}
+
+	err = applyVars(vars, realms)
 	if err != nil {
-		logger.Error("could not make login proxy", zap.Error(err))
+		logger.Error("could not apply settings", zap.Error(err))
 		return 1
 	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := loginPx.ListenAndServe(ctx)
+
+	for name, p := range proxies {
+		name, p := name, p.(listenAndServer)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := p.ListenAndServe(ctx)
+			if err != nil {
+				select {
+				case errCh <- fmt.Errorf("error while serving %s proxy: %w", name, err):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+
+	if vars.adminAddr != "" {
+		handler, err := retroproxy.NewAdminHandler(proxies, storer, logger.Named("admin"))
 		if err != nil {
-			select {
-			case errCh <- fmt.Errorf("error while serving login proxy: %w", err):
-			case <-ctx.Done():
+			logger.Error("could not make admin handler", zap.Error(err))
+			return 1
+		}
+		handler.SetToken(vars.adminToken)
+		network, addr := vars.network, vars.adminAddr
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := serveHTTP(ctx, "admin api", network, addr, handler)
+			if err != nil {
+				select {
+				case errCh <- fmt.Errorf("error while serving admin api: %w", err):
+				case <-ctx.Done():
+				}
 			}
+		}()
+	}
+
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+	defer signal.Stop(hupCh)
+
+loop:
+	for {
+		select {
+		case err := <-errCh:
+			logger.Error(err.Error())
+			return 1
+		case <-hupCh:
+			reload(realms)
+		case <-sigCtx.Done():
+			break loop
 		}
-	}()
+	}
+	// A second signal terminates the process right away.
+	stop()
 
-	gamePx, err := game.NewProxy(
-		gameProxyAddr,
-		storer,
-		logger.Named("game"),
+	logger.Info("shutting down",
+		zap.Duration("grace_period", vars.gracePeriod),
 	)
-	if err != nil {
-		logger.Error("could not make game proxy", zap.Error(err))
+	if !shutdown(shutdowners) {
 		return 1
 	}
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		err := gamePx.ListenAndServe(ctx)
+	return 0
+}
+
+// checkFamily reports an error if host is an IP address that can't be used on network.
+func checkFamily(network, host string) error {
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return nil
+	}
+	ip = ip.Unmap()
+	switch {
+	case network == "tcp4" && !ip.Is4():
+		return fmt.Errorf("%s is not an IPv4 address but network is %s", host, network)
+	case network == "tcp6" && ip.Is4():
+		return fmt.Errorf("%s is not an IPv6 address but network is %s", host, network)
+	}
+	return nil
+}
+
+// isLoopback reports whether addr only listens on a loopback address, which other hosts can't reach.
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+// parseOutbounds returns the outbound proxies set by outboundProxy and accountProxies, or nil if there are none.
+func (s settings) parseOutbounds() (*retroproxy.Outbounds, error) {
+	if s.outboundProxy == "" && len(s.accountProxies) == 0 {
+		return nil, nil
+	}
+
+	outbounds := &retroproxy.Outbounds{ByAccount: make(map[string]*retroproxy.Outbound)}
+	if s.outboundProxy != "" {
+		o, err := retroproxy.ParseOutbound(s.outboundProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid outbound-proxy: %w", err)
+		}
+		outbounds.Default = o
+	}
+	for _, v := range s.accountProxies {
+		account, rawURL, ok := strings.Cut(v, "=")
+		if !ok || account == "" || rawURL == "" {
+			return nil, fmt.Errorf("invalid account-proxy %q: expected account=url", v)
+		}
+		if rawURL == "direct" {
+			outbounds.ByAccount[account] = nil
+			continue
+		}
+		o, err := retroproxy.ParseOutbound(rawURL)
 		if err != nil {
-			select {
-			case errCh <- fmt.Errorf("error while serving game proxy: %w", err):
-			case <-ctx.Done():
+			return nil, fmt.Errorf("invalid account-proxy of account %q: %w", account, err)
+		}
+		outbounds.ByAccount[account] = o
+	}
+	return outbounds, nil
+}
+
+// parseSourceAddrs returns the local addresses set by sourceAddrs and accountSources, or nil if there are none.
+func (s settings) parseSourceAddrs() (*retroproxy.SourceAddrs, error) {
+	if len(s.sourceAddrs) == 0 && len(s.accountSources) == 0 {
+		return nil, nil
+	}
+
+	addrs := &retroproxy.SourceAddrs{ByAccount: make(map[string]netip.Addr)}
+	for _, v := range s.sourceAddrs {
+		addr, err := parseSourceAddr(s.network, v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid source-addr: %w", err)
+		}
+		addrs.Pool = append(addrs.Pool, addr)
+	}
+	for _, v := range s.accountSources {
+		account, rawAddr, ok := strings.Cut(v, "=")
+		if !ok || account == "" || rawAddr == "" {
+			return nil, fmt.Errorf("invalid account-source %q: expected account=ip", v)
+		}
+		addr, err := parseSourceAddr(s.network, rawAddr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid account-source of account %q: %w", account, err)
+		}
+		addrs.ByAccount[account] = addr
+	}
+	return addrs, nil
+}
+
+// parseSourceAddr returns the local IP address s, which must be of the family of network.
+func parseSourceAddr(network, s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	err = checkFamily(network, s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return addr.Unmap(), nil
+}
+
+// restartVars are the settings that can't change without a restart.
+type restartVars struct {
+	debug, bindTickets                              bool
+	network, listeners                              string
+	ticketFile, storeURL, storeToken, ticketKey     string
+	captureDir, captureFile, adminAddr, metricsAddr string
+	adminToken                                      string
+	captureMaxSize                                  int64
+	ticketDur                                       time.Duration
+}
+
+func (s settings) restartVars() restartVars {
+	// The realms and their listeners can't change, while their servers and public addresses can.
+	var listeners []string
+	for _, cfg := range s.realmConfigs() {
+		listeners = append(listeners, fmt.Sprintf("%q %q %q", cfg.Name, cfg.Login, cfg.Game))
+	}
+	return restartVars{
+		debug:          s.debug,
+		bindTickets:    s.bindTickets,
+		network:        s.network,
+		listeners:      strings.Join(listeners, ", "),
+		ticketFile:     s.ticketFile,
+		storeURL:       s.storeURL,
+		storeToken:     s.storeToken,
+		ticketKey:      s.ticketKey,
+		captureDir:     s.captureDir,
+		captureFile:    s.captureFile,
+		adminAddr:      s.adminAddr,
+		adminToken:     s.adminToken,
+		metricsAddr:    s.metricsAddr,
+		captureMaxSize: s.captureMaxSize,
+		ticketDur:      s.ticketDur,
+	}
+}
+
+// reload reads the settings again, on SIGHUP, and applies those that don't need a restart to the running proxies.
+func reload(realms []*realm) {
+	logger.Info("reloading settings")
+	s, err := loadVars()
+	if err != nil {
+		logger.Error("could not reload settings", zap.Error(err))
+		return
+	}
+	if s.restartVars() != vars.restartVars() {
+		logger.Warn("some of the changed settings need a restart to be applied")
+	}
+	err = applyVars(s, realms)
+	if err != nil {
+		logger.Error("could not apply reloaded settings", zap.Error(err))
+		return
+	}
+	vars = s
+	logger.Info("settings reloaded")
+}
+
+// applyVars applies the settings s that can change without a restart. Sessions already started are left as they are.
+// Everything is checked before anything is applied, so that the proxies are left as they were if s is refused.
+func applyVars(s settings, realms []*realm) error {
+	level := zap.InfoLevel
+	if s.debug {
+		level = zap.DebugLevel
+	}
+	if s.logLevelName != "" {
+		var err error
+		level, err = zapcore.ParseLevel(s.logLevelName)
+		if err != nil {
+			return err
+		}
+	}
+
+	accessList, err := retroproxy.ParseAccessList(s.allowClients, s.denyClients)
+	if err != nil {
+		return err
+	}
+	if len(s.allowClients) == 0 && len(s.denyClients) == 0 {
+		accessList = nil
+	}
+
+	b, err := login.ParseBalancing(s.balancing)
+	if err != nil {
+		return err
+	}
+
+	outbounds, err := s.parseOutbounds()
+	if err != nil {
+		return err
+	}
+
+	sources, err := s.parseSourceAddrs()
+	if err != nil {
+		return err
+	}
+
+	configs := make(map[string]realmConfig)
+	for _, cfg := range s.realmConfigs() {
+		configs[cfg.Name] = cfg
+	}
+	type realmUpdate struct {
+		r   *realm
+		cfg realmConfig
+	}
+	var updates []realmUpdate
+	for _, r := range realms {
+		cfg, ok := configs[r.name]
+		if !ok {
+			// The realm was removed, which only takes effect on restart.
+			continue
+		}
+		if r.login != nil {
+			err := login.CheckServerAddrs(cfg.Server)
+			if err != nil {
+				return fmt.Errorf("invalid login server addresses of realm %q: %w", r.name, err)
+			}
+			err = login.CheckGamePublicAddr(cfg.Public)
+			if err != nil {
+				return fmt.Errorf("invalid game proxy public address of realm %q: %w", r.name, err)
+			}
+		}
+		updates = append(updates, realmUpdate{r: r, cfg: cfg})
+	}
+
+	for _, u := range updates {
+		r, cfg := u.r, u.cfg
+		if r.login != nil {
+			// The addresses were checked above, so these can't fail.
+			err := r.login.SetServerAddrs(cfg.Server)
+			if err != nil {
+				return fmt.Errorf("could not set login server addresses of realm %q: %w", r.name, err)
 			}
+			err = r.login.SetGamePublicAddr(cfg.Public)
+			if err != nil {
+				return fmt.Errorf("could not set game proxy public address of realm %q: %w", r.name, err)
+			}
+			r.login.SetBalancing(b)
+			r.login.SetHealthCheckInterval(s.healthInterval)
+			r.login.SetAccessList(accessList)
+			r.login.SetDialTimeout(s.dialTimeout)
+			r.login.SetMaxPktSize(s.maxPktSize)
+			r.login.SetResolveTTL(s.dnsTTL)
+			r.login.SetOutbounds(outbounds)
+			r.login.SetSourceAddrs(sources)
+			r.login.SetLogSecrets(s.logSecrets)
+		}
+		if r.game != nil {
+			r.game.SetPublicAddr(cfg.Public)
+			r.game.SetAccessList(accessList)
+			r.game.SetDialTimeout(s.dialTimeout)
+			r.game.SetMaxPktSize(s.maxPktSize)
+			r.game.SetOutbounds(outbounds)
+			r.game.SetSourceAddrs(sources)
+			r.game.SetLogSecrets(s.logSecrets)
 		}
-	}()
+	}
+	forceAdminEnabled.Store(s.forceAdmin)
+	rewritePortEnabled.Store(s.rewritePort)
+	rewriteIdentityEnabled.Store(s.rewriteIdentity)
+	logLevel.SetLevel(level)
+	return nil
+}
 
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		retroproxy.DeleteOldTicketsLoop(ctx, storer, 10*time.Second)
-	}()
+type listenAndServer interface {
+	ListenAndServe(ctx context.Context) error
+}
 
-	select {
-	case err := <-errCh:
-		logger.Error(err.Error())
-		return 1
-	case <-ctx.Done():
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown shuts the proxies down gracefully, and reports whether their sessions ended within the grace period.
+func shutdown(shutdowners []shutdowner) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), vars.gracePeriod)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var drained atomic.Bool
+	drained.Store(true)
+	for _, v := range shutdowners {
+		v := v
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := v.Shutdown(ctx)
+			if err != nil {
+				drained.Store(false)
+			}
+		}()
 	}
-	return 0
+	wg.Wait()
+
+	if !drained.Load() {
+		logger.Warn("grace period elapsed before every session ended")
+		return false
+	}
+	logger.Info("every session ended")
+	return true
 }
 
-func loadVars() error {
+// loadVars returns the settings, which it reads into fresh ones so that they only replace those in use once valid.
+func loadVars() (settings, error) {
+	var s settings
 	flags := pflag.NewFlagSet("retroproxy", pflag.ContinueOnError)
-	flags.BoolVarP(&debug, "debug", "d", false, "Enable debug mode")
-	flags.StringVarP(&loginServerAddr, "server", "s",
-		"dofusretro-co-production.ankama-games.com:443", "Dofus login server address")
-	flags.StringVarP(&loginProxyAddr, "login", "l", "0.0.0.0:5555", "Dofus login proxy listener address")
-	flags.StringVarP(&gameProxyAddr, "game", "g", "0.0.0.0:5556", "Dofus game proxy listener address")
-	flags.StringVarP(&gameProxyPublicAddr, "public", "p", "127.0.0.1:5556", "Dofus game proxy public address")
-	flags.BoolVarP(&forceAdmin, "admin", "a", false, "Force admin mode on the client")
+	flags.BoolVarP(&s.debug, "debug", "d", false, "Enable debug mode")
+	flags.StringSliceVarP(&s.loginServerAddrs, "server", "s",
+		[]string{"dofusretro-co-production.ankama-games.com:443"}, "Dofus login server addresses")
+	flags.StringVar(&s.balancing, "balancing", "priority", "How the login server of a client is picked among the healthy ones: priority or round-robin")
+	flags.DurationVar(&s.healthInterval, "health-interval", login.DefaultHealthCheckInterval, "How often the login servers are checked when there are several of them (0 to disable)")
+	flags.StringVar(&s.realmName, "realm", "", "Name of the realm of the login and game proxies, whose tickets are kept apart from those of other realms")
+	flags.StringVarP(&s.loginProxyAddr, "login", "l", "0.0.0.0:5555", "Dofus login proxy listener address (disabled if empty)")
+	flags.StringVarP(&s.gameProxyAddr, "game", "g", "0.0.0.0:5556", "Dofus game proxy listener address (disabled if empty)")
+	flags.StringVarP(&s.gameProxyPublicAddr, "public", "p", "127.0.0.1:5556", "Dofus game proxy public address")
+	flags.BoolVarP(&s.forceAdmin, "admin", "a", false, "Force admin mode on the client")
+	flags.BoolVar(&s.rewritePort, "rewrite-port", true, "Replace the port sent by the client with the one of its login server")
+	flags.BoolVar(&s.rewriteIdentity, "rewrite-identity", true, "Replace the identity sent by the client with one generated for its account")
+	flags.StringVarP(&s.ticketFile, "tickets", "t", "", "Ticket store file path (tickets are kept in memory if empty)")
+	flags.StringVar(&s.storeURL, "store", "", "Remote ticket store URL (e.g. http://127.0.0.1:5557)")
+	flags.StringVar(&s.storeToken, "store-token", "", "Bearer token sent to the remote ticket store")
+	flags.StringVar(&s.ticketKey, "ticket-key", "", "Shared key to seal tickets with instead of storing them")
+	flags.DurationVar(&s.ticketDur, "ticket-ttl", retroproxy.DefaultTicketTTL, "Lifetime of the tickets issued to clients")
+	flags.BoolVar(&s.bindTickets, "bind-tickets", false, "Reject tickets redeemed from another IP address than the one they were issued to")
+	flags.BoolVar(&s.logSecrets, "log-secrets", false, "Log credentials and tickets carried by packets unredacted")
+	flags.StringVar(&s.captureDir, "capture-dir", "", "Directory to record sessions to, one file per session")
+	flags.StringVar(&s.captureFile, "capture-file", "", "File to record all sessions to")
+	flags.Int64Var(&s.captureMaxSize, "capture-max-size", 100, "Size in MiB at which the capture file is rotated (0 to disable)")
+	flags.DurationVar(&s.gracePeriod, "grace-period", 30*time.Second, "How long to wait for sessions to end on shutdown before disconnecting them")
+	flags.StringVar(&s.metricsAddr, "metrics", "", "Prometheus metrics listener address (disabled if empty, e.g. 127.0.0.1:9090)")
+	flags.StringVar(&s.adminAddr, "admin-api", "", "Admin HTTP API listener address (disabled if empty, e.g. 127.0.0.1:5558)")
+	flags.StringVar(&s.adminToken, "admin-token", "", "Bearer token required by the admin HTTP API (needed unless it listens on loopback)")
+	flags.StringVar(&s.network, "network", "tcp4", "Network of the listeners and of the connections to servers (tcp, tcp4 or tcp6)")
+	flags.DurationVar(&s.dialTimeout, "dial-timeout", retroproxy.DefaultDialTimeout, "How long to wait for connections to servers to be established")
+	flags.IntVar(&s.maxPktSize, "max-packet-size", codec.DefaultMaxPktSize, "Maximum size in bytes of the packets read from clients and servers, larger ones ending the session")
+	flags.DurationVar(&s.dnsTTL, "dns-ttl", retroproxy.DefaultResolveTTL, "How long the resolved addresses of the login servers are kept (0 to resolve them on each connection)")
+	flags.StringVar(&s.outboundProxy, "outbound-proxy", "", "SOCKS5 or HTTP proxy to connect to servers through (e.g. socks5://127.0.0.1:1080)")
+	flags.StringSliceVar(&s.accountProxies, "account-proxy", nil, "Proxy to connect to game servers through for an account, as account=url, or account=direct")
+	flags.StringSliceVar(&s.sourceAddrs, "source-addr", nil, "Local IP addresses to connect to servers from, picked in turn for each login")
+	flags.StringSliceVar(&s.accountSources, "account-source", nil, "Local IP address to connect to game servers from for an account, as account=ip")
+	flags.StringVarP(&s.configFile, configFlag, "c", "", "Config file path (YAML, keyed by flag names)")
+	flags.StringVar(&s.logLevelName, "log-level", "", "Log level: debug, info, warn or error (debug in debug mode, info otherwise)")
+	flags.StringSliceVar(&s.allowClients, "allow", nil, "IP addresses or CIDR prefixes of the only clients allowed to connect")
+	flags.StringSliceVar(&s.denyClients, "deny", nil, "IP addresses or CIDR prefixes of the clients refused")
 	flags.SortFlags = false
-	return flags.Parse(os.Args)
+	err := flags.Parse(os.Args)
+	if err != nil {
+		return settings{}, err
+	}
+
+	err = applyEnv(flags)
+	if err != nil {
+		return settings{}, err
+	}
+	if s.configFile != "" {
+		s.extraRealms, err = applyConfigFile(flags, s.configFile)
+		if err != nil {
+			return settings{}, err
+		}
+	}
+	err = s.validate()
+	if err != nil {
+		return settings{}, err
+	}
+	return s, nil
+}
+
+// validate reports invalid settings before anything starts.
+func (s settings) validate() error {
+	switch s.network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return fmt.Errorf("invalid network: %s", s.network)
+	}
+	err := s.validateRealms()
+	if err != nil {
+		return err
+	}
+	realms := s.realmConfigs()
+	if len(realms) == 0 {
+		return errors.New("both the login and game proxies are disabled")
+	}
+
+	type namedAddr struct {
+		name, addr string
+	}
+	var addrs []namedAddr
+	listeners := make(map[string]bool)
+	for _, cfg := range realms {
+		for _, addr := range []string{cfg.Login, cfg.Game} {
+			if addr == "" {
+				continue
+			}
+			if listeners[addr] {
+				return fmt.Errorf("listener address %s is used more than once", addr)
+			}
+			listeners[addr] = true
+		}
+		addrs = append(addrs, namedAddr{"login", cfg.Login}, namedAddr{"game", cfg.Game})
+		if cfg.Login == "" {
+			continue
+		}
+		if len(cfg.Server) == 0 {
+			return errors.New("no login server address")
+		}
+		for _, addr := range cfg.Server {
+			if addr == "" {
+				return errors.New("invalid server address: empty")
+			}
+			addrs = append(addrs, namedAddr{"server", addr})
+		}
+		if cfg.Public == "" {
+			return errors.New("invalid public address: empty")
+		}
+		addrs = append(addrs, namedAddr{"public", cfg.Public})
+		// The login proxy also refuses public addresses with a zone, which clients can't connect to.
+		err := login.CheckServerAddrs(cfg.Server)
+		if err != nil {
+			return err
+		}
+		err = login.CheckGamePublicAddr(cfg.Public)
+		if err != nil {
+			return fmt.Errorf("invalid public address: %w", err)
+		}
+	}
+	_, err = login.ParseBalancing(s.balancing)
+	if err != nil {
+		return err
+	}
+	if s.healthInterval < 0 {
+		return errors.New("health-interval must not be negative")
+	}
+	addrs = append(addrs, namedAddr{"admin-api", s.adminAddr}, namedAddr{"metrics", s.metricsAddr})
+	for _, v := range addrs {
+		if v.addr == "" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(v.addr)
+		if err != nil {
+			return fmt.Errorf("invalid %s address: %w", v.name, err)
+		}
+		_, err = strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid %s address: invalid port %q", v.name, port)
+		}
+		// The public address is the one clients connect to, so it doesn't have to be of the network of the proxy.
+		if v.name != "public" {
+			err := checkFamily(s.network, host)
+			if err != nil {
+				return fmt.Errorf("invalid %s address: %w", v.name, err)
+			}
+		}
+	}
+
+	if s.adminAddr != "" && s.adminToken == "" && !isLoopback(s.adminAddr) {
+		return errors.New("admin-token is required when admin-api doesn't listen on a loopback address")
+	}
+
+	if s.ticketDur <= 0 {
+		return errors.New("ticket-ttl must be positive")
+	}
+	if s.dialTimeout <= 0 {
+		return errors.New("dial-timeout must be positive")
+	}
+	if s.maxPktSize <= 0 {
+		return errors.New("max-packet-size must be positive")
+	}
+	if s.dnsTTL < 0 {
+		return errors.New("dns-ttl must not be negative")
+	}
+	if s.gracePeriod < 0 {
+		return errors.New("grace-period must not be negative")
+	}
+	if s.captureMaxSize < 0 {
+		return errors.New("capture-max-size must not be negative")
+	}
+
+	ticketStores := 0
+	for _, v := range []string{s.ticketKey, s.storeURL, s.ticketFile} {
+		if v != "" {
+			ticketStores++
+		}
+	}
+	if ticketStores > 1 {
+		return errors.New("only one of ticket-key, store and tickets can be used")
+	}
+	if s.captureDir != "" && s.captureFile != "" {
+		return errors.New("capture-dir and capture-file can't be used together")
+	}
+	if s.logLevelName != "" {
+		_, err := zapcore.ParseLevel(s.logLevelName)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = retroproxy.ParseAccessList(s.allowClients, s.denyClients)
+	if err != nil {
+		return fmt.Errorf("invalid access list: %w", err)
+	}
+	_, err = s.parseOutbounds()
+	if err != nil {
+		return err
+	}
+	_, err = s.parseSourceAddrs()
+	if err != nil {
+		return err
+	}
+	if s.ticketKey != "" && len(s.ticketKey) < 16 {
+		return errors.New("ticket-key must be at least 16 bytes long")
+	}
+	return nil
+}
+
+func loadLogger(debug bool) error {
+	var cfg zap.Config
+	if debug {
+		cfg = zap.NewDevelopmentConfig()
+	} else {
+		cfg = zap.NewProductionConfig()
+	}
+	logLevel = cfg.Level
+
+	var err error
+	logger, err = cfg.Build()
+	return err
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kralamoure/retroproto"
+
+	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/game"
+	"github.com/kralamoure/retroproxy/login"
+	"github.com/kralamoure/retroproxy/metrics"
+)
+
+// realmsKey is the key of the config file listing the realms served along with the one set by the flags.
+const realmsKey = "realms"
+
+// realmConfig is a login proxy connecting to its own login servers and a game proxy, whose tickets are kept apart from
+// those of the other realms. Its fields are named after the flags of the realm set by the flags.
+type realmConfig struct {
+	Name   string   `yaml:"name"`
+	Login  string   `yaml:"login"`
+	Game   string   `yaml:"game"`
+	Server []string `yaml:"server"`
+	Public string   `yaml:"public"`
+}
+
+// realmConfigs returns the realm set by the flags, unless both of its proxies are disabled, followed by the realms of
+// the config file.
+func (s settings) realmConfigs() []realmConfig {
+	var realms []realmConfig
+	if s.loginProxyAddr != "" || s.gameProxyAddr != "" {
+		realms = append(realms, realmConfig{
+			Name:   s.realmName,
+			Login:  s.loginProxyAddr,
+			Game:   s.gameProxyAddr,
+			Server: s.loginServerAddrs,
+			Public: s.gameProxyPublicAddr,
+		})
+	}
+	return append(realms, s.extraRealms...)
+}
+
+// validateRealms reports realms of the config file without a name or a proxy, realms sharing a name, and names with a
+// colon, which separates the namespace of a realm from the ids of its tickets.
+func (s settings) validateRealms() error {
+	if strings.Contains(s.realmName, ":") {
+		return fmt.Errorf("invalid realm %q: it must not contain a colon", s.realmName)
+	}
+	names := map[string]bool{s.realmName: true}
+	for i, cfg := range s.extraRealms {
+		if cfg.Name == "" {
+			return fmt.Errorf("realm %d of the config file has no name", i+1)
+		}
+		if strings.Contains(cfg.Name, ":") {
+			return fmt.Errorf("invalid realm %q: it must not contain a colon", cfg.Name)
+		}
+		if names[cfg.Name] {
+			return fmt.Errorf("realm %q is defined more than once", cfg.Name)
+		}
+		names[cfg.Name] = true
+		if cfg.Login == "" && cfg.Game == "" {
+			return fmt.Errorf("realm %q: both the login and game proxies are disabled", cfg.Name)
+		}
+	}
+	return nil
+}
+
+// realm is the proxies of a realmConfig. Either of them is nil if disabled.
+type realm struct {
+	name  string
+	login *login.Proxy
+	game  *game.Proxy
+}
+
+// proxyName returns the name of the proxy of kind, login or game, in the realm named realm.
+func proxyName(kind, realm string) string {
+	if realm == "" {
+		return kind
+	}
+	return kind + "/" + realm
+}
+
+// newRealm makes the proxies of cfg. Tickets are stored in storer, in the namespace of the realm.
+func newRealm(cfg realmConfig, storer retroproxy.ContextStorer, recorder capture.Recorder, m *metrics.Metrics) (*realm, error) {
+	storer = retroproxy.Namespace(storer, cfg.Name)
+	r := &realm{name: cfg.Name}
+
+	if cfg.Login != "" {
+		if len(cfg.Server) == 0 {
+			return nil, errors.New("no login server address")
+		}
+		px, err := login.NewProxy(
+			vars.network,
+			cfg.Login,
+			cfg.Server,
+			cfg.Public,
+			storer,
+			vars.ticketDur,
+			logger.Named(proxyName("login", cfg.Name)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not make login proxy: %w", err)
+		}
+		px.SetRecorder(recorder)
+		px.SetMetrics(m)
+		px.HandleServerPkt(retroproto.AccountLoginSuccess,
+			func(ctx context.Context, s login.Session, pkt *login.Packet) error {
+				if !forceAdminEnabled.Load() {
+					return nil
+				}
+				return login.ForceAdmin(ctx, s, pkt)
+			},
+		)
+		px.HandleClientPkt(retroproto.AccountConfiguredPort,
+			func(ctx context.Context, s login.Session, pkt *login.Packet) error {
+				if !rewritePortEnabled.Load() {
+					return nil
+				}
+				return px.RewriteConfiguredPort(ctx, s, pkt)
+			},
+		)
+		px.HandleClientPkt(retroproto.AccountSendIdentity,
+			func(ctx context.Context, s login.Session, pkt *login.Packet) error {
+				if !rewriteIdentityEnabled.Load() {
+					return nil
+				}
+				return px.RewriteIdentity(ctx, s, pkt)
+			},
+		)
+		r.login = px
+	}
+
+	if cfg.Game != "" {
+		px, err := game.NewProxy(
+			vars.network,
+			cfg.Game,
+			storer,
+			vars.bindTickets,
+			logger.Named(proxyName("game", cfg.Name)),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not make game proxy: %w", err)
+		}
+		px.SetRecorder(recorder)
+		px.SetMetrics(m)
+		px.SetPublicAddr(cfg.Public)
+		r.game = px
+	}
+	return r, nil
+}
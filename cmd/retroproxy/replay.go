@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/codec"
+)
+
+var (
+	replayDebug   bool
+	replayAddr    string
+	replaySession string
+	replayAgainst string
+	replaySpeed   float64
+	replayWait    time.Duration
+)
+
+// runReplay replays the client side of a recorded session against an endpoint and compares the packets received with
+// the recorded ones.
+func runReplay(args []string) int {
+	flags, err := loadReplayVars(args)
+	if err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		log.Println(err)
+		return 2
+	}
+	if flags.NArg() != 1 {
+		log.Println("expected the path of a capture file")
+		return 2
+	}
+	if replayAddr == "" {
+		log.Println("missing endpoint address")
+		return 2
+	}
+	if replaySpeed < 0 {
+		log.Println("speed must not be negative")
+		return 2
+	}
+
+	var sendDir, recvDir capture.Direction
+	switch replayAgainst {
+	case "proxy":
+		sendDir, recvDir = capture.FromClient, capture.ToClient
+	case "server":
+		sendDir, recvDir = capture.ToServer, capture.FromServer
+	default:
+		log.Printf("invalid endpoint kind: %s", replayAgainst)
+		return 2
+	}
+
+	err = loadLogger(replayDebug)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer logger.Sync()
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		logger.Error("could not open capture file", zap.Error(err))
+		return 1
+	}
+	recs, err := capture.ReadSession(f, replaySession)
+	f.Close()
+	if err != nil {
+		logger.Error("could not read capture file", zap.Error(err))
+		return 1
+	}
+
+	var sent, expected []capture.Record
+	for _, rec := range recs {
+		switch rec.Direction {
+		case sendDir:
+			sent = append(sent, rec)
+		case recvDir:
+			expected = append(expected, rec)
+		}
+	}
+	logger.Info("replaying session",
+		zap.String("session_id", recs[0].SessionId),
+		zap.String("proxy", recs[0].Proxy),
+		zap.String("account", recs[0].Account),
+		zap.Int("packets_to_send", len(sent)),
+		zap.Int("packets_expected", len(expected)),
+	)
+
+	got, err := replay(sent)
+	if err != nil {
+		logger.Error("could not replay session", zap.Error(err))
+		return 1
+	}
+
+	diffs := printPktDiff(expected, got)
+	fmt.Printf("%d packets expected, %d received, %d differences\n", len(expected), len(got), diffs)
+	if diffs > 0 {
+		return 1
+	}
+	return 0
+}
+
+// replay sends the packets of recs to the endpoint with their original timing scaled by the speed, and returns the
+// packets received until the endpoint closes the connection or stays idle for the wait duration after the last send.
+func replay(recs []capture.Record) ([]string, error) {
+	conn, err := net.DialTimeout("tcp", replayAddr, 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	logger.Info("connected",
+		zap.String("address", conn.RemoteAddr().String()),
+	)
+
+	var (
+		got   []string
+		mu    sync.Mutex
+		recvd = make(chan struct{}, 1)
+		done  = make(chan struct{})
+	)
+	go func() {
+		defer close(done)
+		rd := codec.NewReader(conn, codec.Server, codec.DefaultMaxPktSize)
+		for {
+			pkt, err := rd.ReadPkt()
+			if err != nil {
+				logger.Debug("stopped receiving packets", zap.Error(err))
+				return
+			}
+			logger.Debug("received packet", zap.String("packet", pkt))
+			mu.Lock()
+			got = append(got, pkt)
+			mu.Unlock()
+			select {
+			case recvd <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	wr := codec.NewWriter(conn, codec.Client, codec.DefaultWriteTimeout)
+	start := time.Now()
+	for _, rec := range recs {
+		if replaySpeed > 0 {
+			at := start.Add(time.Duration(float64(rec.Time.Sub(recs[0].Time)) / replaySpeed))
+			select {
+			case <-time.After(time.Until(at)):
+			case <-done:
+			}
+		}
+		if strings.Contains(rec.Packet, retroproxy.RedactedMask) {
+			logger.Warn("sending redacted packet, record with secrets for a faithful replay",
+				zap.String("message_name", rec.MessageName),
+			)
+		}
+		logger.Debug("sending packet", zap.String("packet", rec.Packet))
+		err := wr.WritePkt(rec.Packet)
+		if err != nil {
+			return nil, fmt.Errorf("could not write to endpoint: %w", err)
+		}
+	}
+
+	timer := time.NewTimer(replayWait)
+	defer timer.Stop()
+	for waiting := true; waiting; {
+		select {
+		case <-recvd:
+			timer.Reset(replayWait)
+		case <-timer.C:
+			waiting = false
+		case <-done:
+			waiting = false
+		}
+	}
+	conn.Close()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	return got, nil
+}
+
+// printPktDiff prints the differences between the packets of expected and got, and returns how many packets differ.
+// The masked part of a redacted packet matches anything.
+func printPktDiff(expected []capture.Record, got []string) int {
+	d := &pktDiff{expected: expected, got: got}
+	d.diff(0, len(expected), 0, len(got))
+	return d.diffs
+}
+
+// pktDiff finds the shortest edit script between two sequences of packets with Myers' algorithm, in its linear space
+// variant, so that long captures can be compared.
+type pktDiff struct {
+	expected []capture.Record
+	got      []string
+	diffs    int
+}
+
+func (d *pktDiff) match(i, j int) bool {
+	return matchPkt(d.expected[i].Packet, d.got[j])
+}
+
+func (d *pktDiff) same(j int) {
+	fmt.Printf("  %s\n", d.got[j])
+}
+
+func (d *pktDiff) added(j int) {
+	fmt.Printf("+ %s\n", d.got[j])
+	d.diffs++
+}
+
+func (d *pktDiff) removed(i int) {
+	fmt.Printf("- %s\n", d.expected[i].Packet)
+	d.diffs++
+}
+
+// diff prints the differences between expected[i0:i1] and got[j0:j1].
+func (d *pktDiff) diff(i0, i1, j0, j1 int) {
+	for i0 < i1 && j0 < j1 && d.match(i0, j0) {
+		d.same(j0)
+		i0++
+		j0++
+	}
+	suffix := 0
+	for i0 < i1 && j0 < j1 && d.match(i1-1, j1-1) {
+		i1--
+		j1--
+		suffix++
+	}
+
+	switch {
+	case i0 == i1:
+		for j := j0; j < j1; j++ {
+			d.added(j)
+		}
+	case j0 == j1:
+		for i := i0; i < i1; i++ {
+			d.removed(i)
+		}
+	default:
+		x, y, ok := d.bisect(i0, i1, j0, j1)
+		if ok {
+			d.diff(i0, x, j0, y)
+			d.diff(x, i1, y, j1)
+		} else {
+			for i := i0; i < i1; i++ {
+				d.removed(i)
+			}
+			for j := j0; j < j1; j++ {
+				d.added(j)
+			}
+		}
+	}
+
+	for j := j1; j < j1+suffix; j++ {
+		d.same(j)
+	}
+}
+
+// bisect returns a point of a shortest edit path between expected[i0:i1] and got[j0:j1], found where the paths
+// walked from both ends meet, or false if the sequences have nothing in common.
+func (d *pktDiff) bisect(i0, i1, j0, j1 int) (int, int, bool) {
+	n, m := i1-i0, j1-j0
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	length := 2*maxD + 2
+	v1 := make([]int, length)
+	v2 := make([]int, length)
+	for i := range v1 {
+		v1[i] = -1
+		v2[i] = -1
+	}
+	v1[offset+1] = 0
+	v2[offset+1] = 0
+	delta := n - m
+	// The paths meet on the forward walk if delta is odd, and on the reverse one otherwise.
+	front := delta%2 != 0
+	k1Start, k1End, k2Start, k2End := 0, 0, 0, 0
+
+	for e := 0; e < maxD; e++ {
+		for k1 := -e + k1Start; k1 <= e-k1End; k1 += 2 {
+			k1Offset := offset + k1
+			var x1 int
+			if k1 == -e || (k1 != e && v1[k1Offset-1] < v1[k1Offset+1]) {
+				x1 = v1[k1Offset+1]
+			} else {
+				x1 = v1[k1Offset-1] + 1
+			}
+			y1 := x1 - k1
+			for x1 < n && y1 < m && d.match(i0+x1, j0+y1) {
+				x1++
+				y1++
+			}
+			v1[k1Offset] = x1
+			switch {
+			case x1 > n:
+				k1End += 2
+			case y1 > m:
+				k1Start += 2
+			case front:
+				k2Offset := offset + delta - k1
+				if k2Offset >= 0 && k2Offset < length && v2[k2Offset] != -1 && x1 >= n-v2[k2Offset] {
+					return i0 + x1, j0 + y1, true
+				}
+			}
+		}
+
+		for k2 := -e + k2Start; k2 <= e-k2End; k2 += 2 {
+			k2Offset := offset + k2
+			var x2 int
+			if k2 == -e || (k2 != e && v2[k2Offset-1] < v2[k2Offset+1]) {
+				x2 = v2[k2Offset+1]
+			} else {
+				x2 = v2[k2Offset-1] + 1
+			}
+			y2 := x2 - k2
+			for x2 < n && y2 < m && d.match(i1-x2-1, j1-y2-1) {
+				x2++
+				y2++
+			}
+			v2[k2Offset] = x2
+			switch {
+			case x2 > n:
+				k2End += 2
+			case y2 > m:
+				k2Start += 2
+			case !front:
+				k1Offset := offset + delta - k2
+				if k1Offset >= 0 && k1Offset < length && v1[k1Offset] != -1 {
+					x1 := v1[k1Offset]
+					y1 := offset + x1 - k1Offset
+					if x1 >= n-x2 {
+						return i0 + x1, j0 + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+func matchPkt(expected, got string) bool {
+	prefix, suffix, redacted := strings.Cut(expected, retroproxy.RedactedMask)
+	if !redacted {
+		return expected == got
+	}
+	return len(got) >= len(prefix)+len(suffix) && strings.HasPrefix(got, prefix) && strings.HasSuffix(got, suffix)
+}
+
+func loadReplayVars(args []string) (*pflag.FlagSet, error) {
+	flags := pflag.NewFlagSet("retroproxy replay", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: retroproxy replay [flags] CAPTURE_FILE")
+		flags.PrintDefaults()
+	}
+	flags.BoolVarP(&replayDebug, "debug", "d", false, "Enable debug mode")
+	flags.StringVarP(&replayAddr, "addr", "a", "", "Endpoint address")
+	flags.StringVar(&replayAgainst, "against", "proxy",
+		"Kind of endpoint: \"proxy\" replays the packets the client sent, \"server\" the packets the proxy sent")
+	flags.StringVar(&replaySession, "session", "", "Session id (the first session of the capture if empty)")
+	flags.Float64Var(&replaySpeed, "speed", 1, "Speed factor of the original timing (no delays if 0)")
+	flags.DurationVar(&replayWait, "wait", 3*time.Second, "How long to wait for packets after the last one sent")
+	flags.SortFlags = false
+	err := flags.Parse(args[1:])
+	return flags, err
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+)
+
+func TestMatchPkt(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+		got      string
+		want     bool
+	}{
+		{
+			name:     "equal",
+			expected: "AlK0",
+			got:      "AlK0",
+			want:     true,
+		},
+		{
+			name:     "different",
+			expected: "AlK0",
+			got:      "AlK1",
+		},
+		{
+			name:     "masked suffix",
+			expected: "AT" + retroproxy.RedactedMask,
+			got:      "ATabc123",
+			want:     true,
+		},
+		{
+			name:     "masked suffix of nothing",
+			expected: "AT" + retroproxy.RedactedMask,
+			got:      "AT",
+			want:     true,
+		},
+		{
+			name:     "masked middle",
+			expected: "AYK127.0.0.1:5556;" + retroproxy.RedactedMask + "x",
+			got:      "AYK127.0.0.1:5556;abcx",
+			want:     true,
+		},
+		{
+			name:     "masked prefix differs",
+			expected: "AT" + retroproxy.RedactedMask,
+			got:      "AXabc123",
+		},
+		{
+			name:     "prefix and suffix overlap",
+			expected: "ab" + retroproxy.RedactedMask + "ba",
+			got:      "aba",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchPkt(tt.expected, tt.got)
+			if got != tt.want {
+				t.Fatalf("matchPkt(%q, %q) = %v, want %v", tt.expected, tt.got, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPktDiff(t *testing.T) {
+	masked := "AT" + retroproxy.RedactedMask
+
+	tests := []struct {
+		name     string
+		expected []string
+		got      []string
+	}{
+		{
+			name:     "equal",
+			expected: []string{"a", "b", "c"},
+			got:      []string{"a", "b", "c"},
+		},
+		{
+			name: "empty",
+		},
+		{
+			name:     "nothing received",
+			expected: []string{"a", "b"},
+		},
+		{
+			name: "nothing expected",
+			got:  []string{"a", "b"},
+		},
+		{
+			name:     "nothing in common",
+			expected: []string{"a", "b", "c"},
+			got:      []string{"d", "e"},
+		},
+		{
+			name:     "insertion",
+			expected: []string{"a", "b", "c"},
+			got:      []string{"a", "x", "b", "c"},
+		},
+		{
+			name:     "deletion",
+			expected: []string{"a", "b", "c", "d"},
+			got:      []string{"a", "c", "d"},
+		},
+		{
+			name:     "odd delta",
+			expected: []string{"a", "b", "c", "a", "b", "b", "a"},
+			got:      []string{"c", "b", "a", "b", "a", "c"},
+		},
+		{
+			name:     "even delta",
+			expected: []string{"x", "a", "b", "y", "c", "d"},
+			got:      []string{"a", "z", "b", "c", "w", "d"},
+		},
+		{
+			name:     "repeated packets",
+			expected: strings.Split("aaabbbaaabbb", ""),
+			got:      strings.Split("ababababab", ""),
+		},
+		{
+			name:     "redacted packets",
+			expected: []string{"a", masked, "b", masked, "c"},
+			got:      []string{"a", "ATone", "x", "b", "ATtwo", "c"},
+		},
+		{
+			name:     "redacted packets that don't match",
+			expected: []string{masked, "a", masked},
+			got:      []string{"AXone", "a", "ATtwo", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected := make([]capture.Record, len(tt.expected))
+			for i, pkt := range tt.expected {
+				expected[i] = capture.Record{Packet: pkt}
+			}
+			want := len(tt.expected) + len(tt.got) - 2*lcs(tt.expected, tt.got)
+
+			d := &pktDiff{expected: expected, got: tt.got}
+			d.diff(0, len(expected), 0, len(tt.got))
+			if d.diffs != want {
+				t.Fatalf("diff() found %d differences, want %d", d.diffs, want)
+			}
+
+			if len(tt.expected) == 0 || len(tt.got) == 0 {
+				return
+			}
+			x, y, ok := d.bisect(0, len(tt.expected), 0, len(tt.got))
+			if !ok {
+				if want != len(tt.expected)+len(tt.got) {
+					t.Fatalf("bisect() found no common packet, want a middle point")
+				}
+				return
+			}
+			// The point is on a shortest edit path if the paths to it and from it make up a longest common subsequence.
+			before := lcs(tt.expected[:x], tt.got[:y])
+			after := lcs(tt.expected[x:], tt.got[y:])
+			if before+after != lcs(tt.expected, tt.got) {
+				t.Fatalf("bisect() = %d, %d, which is not on a shortest edit path", x, y)
+			}
+		})
+	}
+}
+
+func TestPktDiffRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pkts := []string{"a", "b", "c", "AT" + retroproxy.RedactedMask, "ATx"}
+	random := func() []string {
+		seq := make([]string, rng.Intn(20))
+		for i := range seq {
+			seq[i] = pkts[rng.Intn(len(pkts))]
+		}
+		return seq
+	}
+
+	for n := 0; n < 1000; n++ {
+		expected, got := random(), random()
+		records := make([]capture.Record, len(expected))
+		for i, pkt := range expected {
+			records[i] = capture.Record{Packet: pkt}
+		}
+		want := len(expected) + len(got) - 2*lcs(expected, got)
+
+		d := &pktDiff{expected: records, got: got}
+		d.diff(0, len(records), 0, len(got))
+		if d.diffs != want {
+			t.Fatalf("diff(%q, %q) found %d differences, want %d", expected, got, d.diffs, want)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of expected and got, matched with matchPkt.
+func lcs(expected, got []string) int {
+	prev := make([]int, len(got)+1)
+	cur := make([]int, len(got)+1)
+	for i := range expected {
+		for j := range got {
+			switch {
+			case matchPkt(expected[i], got[j]):
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(got)]
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.uber.org/zap"
+
+	"github.com/kralamoure/retroproxy"
+)
+
+var (
+	storeDebug       bool
+	storeAddr        string
+	storeTicketFile  string
+	storeNetwork     string
+	storeServerToken string
+)
+
+// runStore runs the ticket store server used by proxies started with the --store flag.
+func runStore(args []string) int {
+	err := loadStoreVars(args)
+	if err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return 0
+		}
+		log.Println(err)
+		return 2
+	}
+
+	err = loadLogger(storeDebug)
+	if err != nil {
+		log.Println(err)
+		return 1
+	}
+	defer logger.Sync()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var storer retroproxy.ContextStorer
+	if storeTicketFile != "" {
+		file, err := retroproxy.OpenFile(storeTicketFile, logger.Named("file"))
+		if err != nil {
+			logger.Error("could not open ticket file", zap.Error(err))
+			return 1
+		}
+		defer file.Close()
+		storer = file
+	} else {
+		storer = retroproxy.AdaptStorer(retroproxy.NewCache(logger.Named("cache")))
+	}
+
+	handler, err := retroproxy.NewStoreHandler(storer, logger.Named("store"))
+	if err != nil {
+		logger.Error("could not make store handler", zap.Error(err))
+		return 1
+	}
+	handler.SetToken(storeServerToken)
+
+	ln, err := net.Listen(storeNetwork, storeAddr)
+	if err != nil {
+		logger.Error("could not listen", zap.Error(err))
+		return 1
+	}
+	logger.Info("listening",
+		zap.String("address", ln.Addr().String()),
+	)
+
+	srv := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 3 * time.Second,
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	err = srv.Serve(ln)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("error while serving ticket store", zap.Error(err))
+		cancel()
+		return 1
+	}
+	logger.Info("stopped listening",
+		zap.String("address", ln.Addr().String()),
+	)
+	return 0
+}
+
+func loadStoreVars(args []string) error {
+	flags := pflag.NewFlagSet("retroproxy store", pflag.ContinueOnError)
+	flags.BoolVarP(&storeDebug, "debug", "d", false, "Enable debug mode")
+	flags.StringVarP(&storeAddr, "listen", "l", "127.0.0.1:5557", "Ticket store listener address")
+	flags.StringVarP(&storeTicketFile, "tickets", "t", "", "Ticket store file path (tickets are kept in memory if empty)")
+	flags.StringVar(&storeNetwork, "network", "tcp4", "Network of the listener (tcp, tcp4 or tcp6)")
+	flags.StringVar(&storeServerToken, "token", "", "Bearer token required from the proxies, which also enables listing the tickets")
+	flags.SortFlags = false
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	switch storeNetwork {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return fmt.Errorf("invalid network: %s", storeNetwork)
+	}
+	// An invalid address is reported by net.Listen.
+	host, _, _ := net.SplitHostPort(storeAddr)
+	err = checkFamily(storeNetwork, host)
+	if err != nil {
+		return fmt.Errorf("invalid listen address: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Package codec implements the framing of Dofus Retro packets. Packets sent by clients end with "\n\x00" and packets
+// sent by servers end with "\x00".
+package codec
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxPktSize is the maximum size of a packet, delimiter included, used by the proxies.
+const DefaultMaxPktSize = 64 << 10
+
+// DefaultWriteTimeout is the time allowed to write a packet, used by the proxies.
+const DefaultWriteTimeout = 10 * time.Second
+
+// minMaxPktSize is the smallest maximum packet size accepted by NewReader.
+const minMaxPktSize = 16
+
+var ErrPktTooLarge = errors.New("packet too large")
+
+// Side is the side of a connection that sends the packets.
+type Side int
+
+const (
+	Client Side = iota
+	Server
+)
+
+func (s Side) suffix() string {
+	if s == Client {
+		return "\n\x00"
+	}
+	return "\x00"
+}
+
+// Reader reads packets sent by one side of a connection.
+type Reader struct {
+	rd   *bufio.Reader
+	from Side
+}
+
+// NewReader returns a Reader of the packets sent by from over r. Packets larger than maxSize bytes, delimiter
+// included, make ReadPkt fail with ErrPktTooLarge.
+func NewReader(r io.Reader, from Side, maxSize int) *Reader {
+	if maxSize < minMaxPktSize {
+		maxSize = minMaxPktSize
+	}
+	return &Reader{
+		rd:   bufio.NewReaderSize(r, maxSize),
+		from: from,
+	}
+}
+
+// ReadPkt returns the next non-empty packet, without its delimiter.
+func (r *Reader) ReadPkt() (string, error) {
+	for {
+		b, err := r.rd.ReadSlice('\x00')
+		if err != nil {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				return "", ErrPktTooLarge
+			}
+			if errors.Is(err, io.EOF) && len(b) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		pkt := string(b[:len(b)-1])
+		if r.from == Client {
+			pkt = strings.TrimSuffix(pkt, "\n")
+		}
+		if pkt == "" {
+			continue
+		}
+		return pkt, nil
+	}
+}
+
+type deadlineWriter interface {
+	io.Writer
+	SetWriteDeadline(t time.Time) error
+}
+
+// Writer writes packets as one side of a connection. It's safe for concurrent use.
+type Writer struct {
+	w       io.Writer
+	suffix  string
+	timeout time.Duration
+	mu      sync.Mutex
+}
+
+// NewWriter returns a Writer of packets sent by from over w. If w has a SetWriteDeadline method, like net.Conn, and
+// timeout is positive, each packet must be written within timeout.
+func NewWriter(w io.Writer, from Side, timeout time.Duration) *Writer {
+	return &Writer{
+		w:       w,
+		suffix:  from.suffix(),
+		timeout: timeout,
+	}
+}
+
+// WritePkt writes pkt followed by its delimiter.
+func (w *Writer) WritePkt(pkt string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if dw, ok := w.w.(deadlineWriter); ok && w.timeout > 0 {
+		err := dw.SetWriteDeadline(time.Now().Add(w.timeout))
+		if err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w.w, pkt+w.suffix)
+	return err
+}
@@ -0,0 +1,167 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadPkt(t *testing.T) {
+	tests := []struct {
+		name string
+		from Side
+		in   string
+		want []string
+	}{
+		{
+			name: "client",
+			from: Client,
+			in:   "AV\n\x00Af\n\x00",
+			want: []string{"AV", "Af"},
+		},
+		{
+			name: "client without newline",
+			from: Client,
+			in:   "AV\x00",
+			want: []string{"AV"},
+		},
+		{
+			name: "server",
+			from: Server,
+			in:   "HCabc\x00AlK0\x00",
+			want: []string{"HCabc", "AlK0"},
+		},
+		{
+			name: "server keeps newlines",
+			from: Server,
+			in:   "a\nb\n\x00",
+			want: []string{"a\nb\n"},
+		},
+		{
+			name: "empty packets are skipped",
+			from: Client,
+			in:   "\n\x00\x00AV\n\x00",
+			want: []string{"AV"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReader(strings.NewReader(tt.in), tt.from, DefaultMaxPktSize)
+			for _, want := range tt.want {
+				got, err := r.ReadPkt()
+				if err != nil {
+					t.Fatalf("ReadPkt() error = %v", err)
+				}
+				if got != want {
+					t.Fatalf("ReadPkt() = %q, want %q", got, want)
+				}
+			}
+			_, err := r.ReadPkt()
+			if err != io.EOF {
+				t.Fatalf("ReadPkt() error = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestReadPktTooLarge(t *testing.T) {
+	const maxSize = 32
+
+	fits := strings.Repeat("a", maxSize-1) + "\x00"
+	r := NewReader(strings.NewReader(fits), Server, maxSize)
+	got, err := r.ReadPkt()
+	if err != nil {
+		t.Fatalf("ReadPkt() of a packet of the maximum size: error = %v", err)
+	}
+	if got != fits[:maxSize-1] {
+		t.Fatalf("ReadPkt() = %q, want %q", got, fits[:maxSize-1])
+	}
+
+	tooLarge := strings.Repeat("a", maxSize) + "\x00"
+	r = NewReader(strings.NewReader(tooLarge), Server, maxSize)
+	_, err = r.ReadPkt()
+	if !errors.Is(err, ErrPktTooLarge) {
+		t.Fatalf("ReadPkt() of a packet over the maximum size: error = %v, want %v", err, ErrPktTooLarge)
+	}
+}
+
+func TestReadPktUnexpectedEOF(t *testing.T) {
+	r := NewReader(strings.NewReader("AV\n\x00Af"), Client, DefaultMaxPktSize)
+	_, err := r.ReadPkt()
+	if err != nil {
+		t.Fatalf("ReadPkt() error = %v", err)
+	}
+	_, err = r.ReadPkt()
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("ReadPkt() error = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestWritePkt(t *testing.T) {
+	tests := []struct {
+		from Side
+		want string
+	}{
+		{from: Client, want: "AV\n\x00"},
+		{from: Server, want: "AV\x00"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := NewWriter(&buf, tt.from, DefaultWriteTimeout).WritePkt("AV")
+		if err != nil {
+			t.Fatalf("WritePkt() error = %v", err)
+		}
+		if buf.String() != tt.want {
+			t.Fatalf("WritePkt() wrote %q, want %q", buf.String(), tt.want)
+		}
+	}
+}
+
+type deadlineRecorder struct {
+	bytes.Buffer
+	deadlines []time.Time
+	err       error
+}
+
+func (w *deadlineRecorder) SetWriteDeadline(t time.Time) error {
+	w.deadlines = append(w.deadlines, t)
+	return w.err
+}
+
+func TestWritePktDeadline(t *testing.T) {
+	const timeout = time.Minute
+
+	w := &deadlineRecorder{}
+	before := time.Now()
+	err := NewWriter(w, Server, timeout).WritePkt("AV")
+	if err != nil {
+		t.Fatalf("WritePkt() error = %v", err)
+	}
+	if len(w.deadlines) != 1 {
+		t.Fatalf("SetWriteDeadline() called %d times, want 1", len(w.deadlines))
+	}
+	if d := w.deadlines[0]; d.Before(before.Add(timeout)) || d.After(time.Now().Add(timeout)) {
+		t.Fatalf("deadline = %v, want %v from the write", d, timeout)
+	}
+
+	w = &deadlineRecorder{}
+	err = NewWriter(w, Server, 0).WritePkt("AV")
+	if err != nil {
+		t.Fatalf("WritePkt() error = %v", err)
+	}
+	if len(w.deadlines) != 0 {
+		t.Fatalf("SetWriteDeadline() called without a timeout")
+	}
+
+	w = &deadlineRecorder{err: errors.New("closed")}
+	err = NewWriter(w, Server, timeout).WritePkt("AV")
+	if err == nil {
+		t.Fatalf("WritePkt() error = nil, want the deadline error")
+	}
+	if w.Len() != 0 {
+		t.Fatalf("WritePkt() wrote %q despite the deadline error", w.String())
+	}
+}
@@ -0,0 +1,131 @@
+package retroproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultDialTimeout is the time the proxies wait for connections to servers to be established by default.
+const DefaultDialTimeout = 3 * time.Second
+
+// DefaultResolveTTL is how long a Resolver keeps the addresses of a host by default.
+const DefaultResolveTTL = 30 * time.Second
+
+// Resolver resolves the host names of servers when connecting to them, so that DNS changes are picked up without a
+// restart. The addresses of a host are kept for a while to spare a lookup for every connection.
+type Resolver struct {
+	ttl   time.Duration
+	hosts map[string]resolvedHost
+	mu    sync.Mutex
+}
+
+type resolvedHost struct {
+	addrs     []netip.Addr
+	expiresAt time.Time
+}
+
+// NewResolver returns a Resolver keeping the addresses of a host for ttl, or not at all if ttl is not positive.
+func NewResolver(ttl time.Duration) *Resolver {
+	return &Resolver{ttl: ttl}
+}
+
+// SetTTL sets how long the addresses of a host are kept from the next lookup on, or disables keeping them if d is not
+// positive.
+func (r *Resolver) SetTTL(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = d
+	if d <= 0 {
+		r.hosts = nil
+	}
+}
+
+// Resolve returns the addresses of host usable on network, which is tcp, tcp4 or tcp6, in the order they should be
+// tried. An IP address is returned as is.
+func (r *Resolver) Resolve(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+
+	ipNetwork := "ip"
+	switch network {
+	case "tcp4":
+		ipNetwork = "ip4"
+	case "tcp6":
+		ipNetwork = "ip6"
+	}
+	key := ipNetwork + " " + host
+
+	r.mu.Lock()
+	cached, ok := r.hosts[key]
+	r.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.addrs, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, ipNetwork, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no address found for %s", host)
+	}
+	for i := range addrs {
+		addrs[i] = addrs[i].Unmap()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ttl > 0 {
+		if r.hosts == nil {
+			r.hosts = make(map[string]resolvedHost)
+		}
+		r.hosts[key] = resolvedHost{addrs: addrs, expiresAt: time.Now().Add(r.ttl)}
+	}
+	return addrs, nil
+}
+
+// Dial resolves the host of addr and connects to its addresses in order until one accepts the connection, waiting
+// up to timeout for each of them. The connection is made from source unless it's the zero address; when it's not, the
+// addresses of another family than source are skipped.
+func (r *Resolver) Dial(ctx context.Context, network, addr string, source netip.Addr, timeout time.Duration) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	ips, err := r.Resolve(ctx, network, host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := newDialer(timeout, source)
+	var errs []error
+	for _, ip := range ips {
+		if source.IsValid() && ip.Is4() != source.Unmap().Is4() {
+			continue
+		}
+		conn, err := dialer.DialContext(ctx, network, netip.AddrPortFrom(ip, uint16(port)).String())
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("no address of %s is of the family of %s", host, source)
+	}
+	return nil, errors.Join(errs...)
+}
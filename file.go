@@ -0,0 +1,338 @@
+package retroproxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/kralamoure/retroproxy/metrics"
+)
+
+// minCompactRecords is the number of records a File log must reach before it is considered for compaction.
+const minCompactRecords = 64
+
+const (
+	fileOpSet = "set"
+	fileOpDel = "del"
+)
+
+type fileRecord struct {
+	Op     string  `json:"op"`
+	Id     string  `json:"id"`
+	Ticket *Ticket `json:"ticket,omitempty"`
+}
+
+// File is an implementation of ContextStorer backed by an append-only log file, so that tickets survive restarts of
+// the proxy. The log is replayed when opened and rewritten with only the live tickets once most of its records are
+// stale.
+type File struct {
+	logger  *zap.Logger
+	path    string
+	f       *os.File
+	tickets map[string]Ticket
+	timers  map[string]*time.Timer
+	ttl     time.Duration
+	records int
+	metrics *metrics.Metrics
+	mu      sync.Mutex
+}
+
+func OpenFile(path string, logger *zap.Logger) (*File, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	r := &File{
+		logger:  logger,
+		path:    path,
+		tickets: make(map[string]Ticket),
+		timers:  make(map[string]*time.Timer),
+		ttl:     DefaultTicketTTL,
+	}
+
+	err := r.load()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for id, t := range r.tickets {
+		t = t.withExpiry(r.ttl)
+		if t.Expired(now) {
+			delete(r.tickets, id)
+			continue
+		}
+		r.tickets[id] = t
+		r.schedule(id, t)
+	}
+
+	err = r.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *File) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, timer := range r.timers {
+		timer.Stop()
+		delete(r.timers, id)
+	}
+	return r.f.Close()
+}
+
+func (r *File) SetTicket(ctx context.Context, id string, t Ticket) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t = t.withExpiry(r.ttl)
+	err = r.append(fileRecord{Op: fileOpSet, Id: id, Ticket: &t})
+	if err != nil {
+		return err
+	}
+	r.tickets[id] = t
+	r.schedule(id, t)
+	r.maybeCompact()
+	r.logger.Debug("ticket set",
+		zap.String("ticket_id", redactTicketId(id)),
+		zap.String("ticket", fmt.Sprintf("%+v", t)),
+	)
+	return nil
+}
+
+func (r *File) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	err := ctx.Err()
+	if err != nil {
+		return Ticket{}, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tickets[id]
+	if !ok {
+		return Ticket{}, ErrTicketNotFound
+	}
+	err = r.delete(id)
+	if err != nil {
+		return Ticket{}, err
+	}
+	if t.Expired(time.Now()) {
+		return Ticket{}, ErrTicketNotFound
+	}
+	r.logger.Debug("ticket used",
+		zap.String("ticket_id", redactTicketId(id)),
+	)
+	return t, nil
+}
+
+func (r *File) Tickets(ctx context.Context) (map[string]Ticket, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	tickets := make(map[string]Ticket, len(r.tickets))
+	for id, t := range r.tickets {
+		if !t.Expired(now) {
+			tickets[id] = t
+		}
+	}
+	return tickets, nil
+}
+
+// SetTicketTTL sets the lifetime of the tickets set from now on without their own expiry, counted from when they were
+// issued. The tickets without an expiry read when the file was opened were given DefaultTicketTTL.
+func (r *File) SetTicketTTL(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = d
+}
+
+// SetMetrics sets the metrics counting the expired tickets, or disables them if m is nil.
+func (r *File) SetMetrics(m *metrics.Metrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics = m
+}
+
+// schedule arranges for the ticket identified by id to be deleted once t expires.
+func (r *File) schedule(id string, t Ticket) {
+	if timer, ok := r.timers[id]; ok {
+		timer.Stop()
+		delete(r.timers, id)
+	}
+	if t.ExpiresAt.IsZero() {
+		return
+	}
+	r.timers[id] = time.AfterFunc(time.Until(t.ExpiresAt), func() {
+		r.expire(id, t.ExpiresAt)
+	})
+}
+
+// expire deletes the ticket identified by id if it's still the one that expires at expiresAt.
+func (r *File) expire(id string, expiresAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tickets[id]
+	if !ok || !t.ExpiresAt.Equal(expiresAt) {
+		return
+	}
+	err := r.delete(id)
+	if err != nil {
+		r.logger.Error("could not delete expired ticket",
+			zap.Error(err),
+			zap.String("ticket_id", redactTicketId(id)),
+		)
+		return
+	}
+	r.metrics.TicketExpired()
+	r.logger.Debug("expired ticket deleted",
+		zap.String("ticket_id", redactTicketId(id)),
+	)
+}
+
+// delete deletes the ticket identified by id, unless its deletion could not be written.
+func (r *File) delete(id string) error {
+	err := r.append(fileRecord{Op: fileOpDel, Id: id})
+	if err != nil {
+		return err
+	}
+	delete(r.tickets, id)
+	if timer, ok := r.timers[id]; ok {
+		timer.Stop()
+		delete(r.timers, id)
+	}
+	r.maybeCompact()
+	return nil
+}
+
+// maybeCompact compacts the log once most of its records are stale. The record just written is already in the log,
+// so a failure is only logged.
+func (r *File) maybeCompact() {
+	if r.records < minCompactRecords || r.records <= 2*len(r.tickets) {
+		return
+	}
+	err := r.compact()
+	if err != nil {
+		r.logger.Error("could not compact ticket file", zap.Error(err))
+	}
+}
+
+func (r *File) append(rec fileRecord) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = r.f.Write(append(b, '\n'))
+	if err != nil {
+		return err
+	}
+	r.records++
+	return nil
+}
+
+func (r *File) load() error {
+	f, err := os.Open(r.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	line := 0
+	for sc.Scan() {
+		line++
+		var rec fileRecord
+		err := json.Unmarshal(sc.Bytes(), &rec)
+		if err != nil {
+			// A process killed while writing can leave a truncated last record behind.
+			r.logger.Warn("skipping invalid record in ticket file",
+				zap.Error(err),
+				zap.String("path", r.path),
+				zap.Int("line", line),
+			)
+			continue
+		}
+		switch rec.Op {
+		case fileOpSet:
+			if rec.Ticket != nil {
+				r.tickets[rec.Id] = *rec.Ticket
+			}
+		case fileOpDel:
+			delete(r.tickets, rec.Id)
+		}
+	}
+	return sc.Err()
+}
+
+// compact rewrites the log with only the live tickets and swaps it in place of the current one.
+func (r *File) compact() error {
+	tmpPath := r.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+
+	wr := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(wr)
+	for id := range r.tickets {
+		t := r.tickets[id]
+		err := enc.Encode(fileRecord{Op: fileOpSet, Id: id, Ticket: &t})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	err = wr.Flush()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Sync()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, r.path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if r.f != nil {
+		r.f.Close()
+	}
+	r.f = f
+	r.records = len(r.tickets)
+
+	r.logger.Debug("ticket file compacted",
+		zap.String("path", r.path),
+		zap.Int("tickets", len(r.tickets)),
+	)
+	return nil
+}
@@ -0,0 +1,235 @@
+package retroproxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileReopen(t *testing.T) {
+	ctx := context.Background()
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		set  map[string]Ticket
+		use  []string
+		want []string
+	}{
+		{
+			name: "set tickets are kept",
+			set: map[string]Ticket{
+				"a": {Host: "127.0.0.1", ExpiresAt: future},
+				"b": {Host: "127.0.0.2", ExpiresAt: future},
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "used tickets are forgotten",
+			set: map[string]Ticket{
+				"a": {Host: "127.0.0.1", ExpiresAt: future},
+				"b": {Host: "127.0.0.2", ExpiresAt: future},
+			},
+			use:  []string{"a"},
+			want: []string{"b"},
+		},
+		{
+			name: "expired tickets are dropped",
+			set: map[string]Ticket{
+				"a": {Host: "127.0.0.1", ExpiresAt: past},
+				"b": {Host: "127.0.0.2", ExpiresAt: future},
+			},
+			want: []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tickets")
+			f, err := OpenFile(path, nil)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			for id, ticket := range tt.set {
+				err := f.SetTicket(ctx, id, ticket)
+				if err != nil {
+					t.Fatalf("SetTicket(%q) error = %v", id, err)
+				}
+			}
+			for _, id := range tt.use {
+				_, err := f.UseTicket(ctx, id)
+				if err != nil {
+					t.Fatalf("UseTicket(%q) error = %v", id, err)
+				}
+			}
+			err = f.Close()
+			if err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			f, err = OpenFile(path, nil)
+			if err != nil {
+				t.Fatalf("OpenFile() again error = %v", err)
+			}
+			defer f.Close()
+			tickets, err := f.Tickets(ctx)
+			if err != nil {
+				t.Fatalf("Tickets() error = %v", err)
+			}
+			if len(tickets) != len(tt.want) {
+				t.Fatalf("Tickets() = %v, want ids %v", tickets, tt.want)
+			}
+			for _, id := range tt.want {
+				got, err := f.UseTicket(ctx, id)
+				if err != nil {
+					t.Fatalf("UseTicket(%q) after reopening error = %v", id, err)
+				}
+				if got.Host != tt.set[id].Host {
+					t.Fatalf("UseTicket(%q) after reopening = %+v, want host %q", id, got, tt.set[id].Host)
+				}
+			}
+		})
+	}
+}
+
+func TestFileUseTicketTwice(t *testing.T) {
+	ctx := context.Background()
+	f, err := OpenFile(filepath.Join(t.TempDir(), "tickets"), nil)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer f.Close()
+
+	err = f.SetTicket(ctx, "a", Ticket{ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("SetTicket() error = %v", err)
+	}
+	_, err = f.UseTicket(ctx, "a")
+	if err != nil {
+		t.Fatalf("UseTicket() error = %v", err)
+	}
+	_, err = f.UseTicket(ctx, "a")
+	if !errors.Is(err, ErrTicketNotFound) {
+		t.Fatalf("UseTicket() a second time error = %v, want %v", err, ErrTicketNotFound)
+	}
+}
+
+func TestFileCompaction(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		tickets int
+		use     bool
+	}{
+		{
+			name:    "redeemed tickets",
+			tickets: 10 * minCompactRecords,
+			use:     true,
+		},
+		{
+			name:    "live tickets",
+			tickets: 2 * minCompactRecords,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tickets")
+			f, err := OpenFile(path, nil)
+			if err != nil {
+				t.Fatalf("OpenFile() error = %v", err)
+			}
+			defer f.Close()
+
+			for i := 0; i < tt.tickets; i++ {
+				id := strconv.Itoa(i)
+				err := f.SetTicket(ctx, id, Ticket{ExpiresAt: time.Now().Add(time.Hour)})
+				if err != nil {
+					t.Fatalf("SetTicket(%q) error = %v", id, err)
+				}
+				if tt.use {
+					_, err := f.UseTicket(ctx, id)
+					if err != nil {
+						t.Fatalf("UseTicket(%q) error = %v", id, err)
+					}
+				}
+			}
+
+			live := tt.tickets
+			if tt.use {
+				live = 0
+			}
+			// A log is compacted once it has more than twice as many records as live tickets.
+			max := 2 * live
+			if max < minCompactRecords {
+				max = minCompactRecords
+			}
+			got := countLines(t, path)
+			if got > max {
+				t.Fatalf("ticket file has %d records, want at most %d", got, max)
+			}
+			if got < live {
+				t.Fatalf("ticket file has %d records, want at least %d", got, live)
+			}
+		})
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("could not open %s: %v", path, err)
+	}
+	defer f.Close()
+	n := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		n++
+	}
+	if err := sc.Err(); err != nil {
+		t.Fatalf("could not read %s: %v", path, err)
+	}
+	return n
+}
+
+func TestFileCanceledContext(t *testing.T) {
+	f, err := OpenFile(filepath.Join(t.TempDir(), "tickets"), nil)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	defer f.Close()
+	err = f.SetTicket(context.Background(), "a", Ticket{ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("SetTicket() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = f.SetTicket(ctx, "b", Ticket{ExpiresAt: time.Now().Add(time.Hour)})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("SetTicket() error = %v, want %v", err, context.Canceled)
+	}
+	_, err = f.UseTicket(ctx, "a")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("UseTicket() error = %v, want %v", err, context.Canceled)
+	}
+	_, err = f.Tickets(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Tickets() error = %v, want %v", err, context.Canceled)
+	}
+
+	// Nothing was done with the canceled context.
+	tickets, err := f.Tickets(context.Background())
+	if err != nil {
+		t.Fatalf("Tickets() error = %v", err)
+	}
+	if _, ok := tickets["a"]; !ok || len(tickets) != 1 {
+		t.Fatalf("Tickets() = %v, want only a", tickets)
+	}
+}
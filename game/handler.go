@@ -0,0 +1,71 @@
+package game
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/kralamoure/retroproto"
+	"github.com/kralamoure/retroproto/msgsvr"
+	"go.uber.org/zap"
+
+	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/internal/relay"
+)
+
+// Session is the view of a game session given to packet handlers.
+type Session interface {
+	ClientAddr() net.Addr
+	// ServerAddr is the remote address of the connection to the server, which is the address of the outbound proxy
+	// when the connection goes through one.
+	ServerAddr() net.Addr
+	// UpstreamAddr is the address of the game server given by the ticket of the session, as host:port.
+	UpstreamAddr() string
+	Ticket() retroproxy.Ticket
+
+	// SendPktToClient injects a packet into the stream going to the client.
+	SendPktToClient(pkt string) error
+	// SendPktToServer injects a packet into the stream going to the server.
+	SendPktToServer(pkt string) error
+}
+
+// Packet is a packet going through the handlers of a session.
+type Packet = relay.Packet
+
+// Handler handles a packet forwarded by a session. Returning an error ends the session.
+type Handler = relay.Handler[Session]
+
+// HandleServerPkt registers h for the packets of message id forwarded from the server to the client. An empty id
+// matches every packet. Handlers run in the order they were registered.
+func (p *Proxy) HandleServerPkt(id retroproto.MsgSvrId, h Handler) {
+	p.handlers.HandleServerPkt(id, h)
+}
+
+// HandleClientPkt registers h for the packets of message id forwarded from the client to the server. An empty id
+// matches every packet. Handlers run in the order they were registered.
+func (p *Proxy) HandleClientPkt(id retroproto.MsgCliId, h Handler) {
+	p.handlers.HandleClientPkt(id, h)
+}
+
+// logCharacters logs the characters spotted on the map of the client.
+func (p *Proxy) logCharacters(ctx context.Context, s Session, pkt *Packet) error {
+	msg := &msgsvr.GameMovement{}
+	err := msg.Deserialize(strings.TrimPrefix(pkt.Data, string(retroproto.GameMovement)))
+	if err != nil {
+		return err
+	}
+
+	for _, sprite := range msg.Sprites {
+		if sprite.Fight {
+			continue
+		}
+		if sprite.Type < 1 {
+			continue
+		}
+		p.logger.Debug("character spotted",
+			zap.String("character_name", sprite.Character.Name),
+			zap.Int("character_level", sprite.Character.Level),
+		)
+	}
+	return nil
+}
@@ -5,25 +5,49 @@ import (
 	"errors"
 	"io"
 	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/gofrs/uuid"
+	"github.com/kralamoure/retroproto"
 	"github.com/kralamoure/retroproto/msgsvr"
 	"go.uber.org/zap"
 
 	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/codec"
+	"github.com/kralamoure/retroproxy/internal/relay"
+	"github.com/kralamoure/retroproxy/metrics"
 )
 
 type Proxy struct {
-	logger *zap.Logger
-	addr   *net.TCPAddr
-	storer retroproxy.Storer
+	logger       *zap.Logger
+	network      string
+	addr         *net.TCPAddr
+	storer       retroproxy.ContextStorer
+	bindTicketIP bool
+	public       string // guarded by mu
+	accessList   atomic.Pointer[retroproxy.AccessList]
+	outbounds    atomic.Pointer[retroproxy.Outbounds]
+	sourceAddrs  atomic.Pointer[retroproxy.SourceAddrs]
+
+	logSecrets   atomic.Bool
+	dialTimeout  atomic.Int64 // time.Duration
+	maxPktSize   atomic.Int64
+	shuttingDown atomic.Bool
+	recorder     capture.Recorder
+	metrics      *metrics.Metrics
 
 	ln       *net.TCPListener
-	sessions map[*session]struct{}
+	sessions relay.Sessions[*session]
 	mu       sync.Mutex
+
+	handlers relay.Handlers[Session]
 }
 
-func NewProxy(addr string, storer retroproxy.Storer, logger *zap.Logger) (*Proxy, error) {
+func NewProxy(network, addr string, storer retroproxy.ContextStorer, bindTicketIP bool, logger *zap.Logger) (*Proxy, error) {
 	if storer == nil {
 		return nil, errors.New("storer is nil")
 	}
@@ -31,22 +55,28 @@ func NewProxy(addr string, storer retroproxy.Storer, logger *zap.Logger) (*Proxy
 		logger = zap.NewNop()
 	}
 
-	tcpAddr, err := net.ResolveTCPAddr("tcp4", addr)
+	tcpAddr, err := net.ResolveTCPAddr(network, addr)
 	if err != nil {
 		return nil, err
 	}
-	return &Proxy{
-		logger: logger,
-		addr:   tcpAddr,
-		storer: storer,
-	}, nil
+	p := &Proxy{
+		logger:       logger,
+		network:      network,
+		addr:         tcpAddr,
+		storer:       storer,
+		bindTicketIP: bindTicketIP,
+	}
+	p.dialTimeout.Store(int64(retroproxy.DefaultDialTimeout))
+	p.maxPktSize.Store(codec.DefaultMaxPktSize)
+	p.HandleServerPkt(retroproto.GameMovement, p.logCharacters)
+	return p, nil
 }
 
 func (p *Proxy) ListenAndServe(ctx context.Context) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	ln, err := net.ListenTCP("tcp4", p.addr)
+	ln, err := net.ListenTCP(p.network, p.addr)
 	if err != nil {
 		return err
 	}
@@ -59,13 +89,15 @@ func (p *Proxy) ListenAndServe(ctx context.Context) error {
 	p.logger.Info("listening",
 		zap.String("address", ln.Addr().String()),
 	)
+	p.mu.Lock()
 	p.ln = ln
+	p.mu.Unlock()
 
 	errCh := make(chan error)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := p.acceptLoop(ctx)
+		err := p.acceptLoop(ctx, ln)
 		if err != nil {
 			select {
 			case errCh <- err:
@@ -78,25 +110,51 @@ func (p *Proxy) ListenAndServe(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	case err := <-errCh:
+		if p.shuttingDown.Load() && errors.Is(err, net.ErrClosed) {
+			return nil
+		}
 		return err
 	}
 }
 
-func (p *Proxy) acceptLoop(ctx context.Context) error {
+// Shutdown stops the proxy gracefully: it stops accepting clients, warns the connected ones that the proxy is about to
+// close, and waits for their sessions to end. If ctx is done first, the remaining sessions are disconnected and
+// ctx.Err() is returned. ListenAndServe returns nil once every session has ended.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.shuttingDown.Store(true)
+
+	p.mu.Lock()
+	if p.ln != nil {
+		p.ln.Close()
+	}
+	p.mu.Unlock()
+
+	return p.sessions.Shutdown(ctx, p.logger)
+}
+
+func (p *Proxy) acceptLoop(ctx context.Context, ln *net.TCPListener) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
 	for {
-		conn, err := p.ln.AcceptTCP()
+		conn, err := ln.AcceptTCP()
 		if err != nil {
 			return err
 		}
+		if !p.accessList.Load().Allowed(conn.RemoteAddr()) {
+			p.logger.Info("client refused by access list",
+				zap.String("client_address", conn.RemoteAddr().String()),
+			)
+			conn.Close()
+			continue
+		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			err := p.handleClientConn(ctx, conn)
-			if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, context.Canceled) {
+			if err != nil && !(errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) ||
+				errors.Is(err, relay.ErrKicked) || errors.Is(err, relay.ErrShutdown)) {
 				p.logger.Debug("error while handling client connection",
 					zap.Error(err),
 					zap.String("client_address", conn.RemoteAddr().String()),
@@ -106,33 +164,63 @@ func (p *Proxy) acceptLoop(ctx context.Context) error {
 	}
 }
 
-func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) error {
+func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) (err error) {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	sessionId, err := uuid.NewV4()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
 	defer func() {
 		conn.Close()
 		p.logger.Info("client disconnected",
 			zap.String("client_address", conn.RemoteAddr().String()),
+			zap.String("session_id", sessionId.String()),
+			zap.NamedError("reason", err),
 		)
 	}()
 	p.logger.Info("client connected",
 		zap.String("client_address", conn.RemoteAddr().String()),
+		zap.String("session_id", sessionId.String()),
 	)
 
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	s := &session{
+		id:                  sessionId.String(),
 		proxy:               p,
 		clientConn:          conn,
+		clientWr:            codec.NewWriter(conn, codec.Server, codec.DefaultWriteTimeout),
 		ticketCh:            make(chan retroproxy.Ticket),
 		connectedToServerCh: make(chan struct{}),
+		connectedAt:         time.Now(),
+		cancel:              cancel,
 		firstPkt:            true,
 	}
 
 	p.trackSession(s, true)
 	defer p.trackSession(s, false)
 
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	s.metrics.SessionStarted("game")
+	defer func() {
+		s.metrics.SessionEnded("game", time.Since(s.connectedAt))
+	}()
+
+	if s.recorder != nil {
+		defer func() {
+			err := s.recorder.EndSession(s.id)
+			if err != nil {
+				p.logger.Warn("could not end session recording",
+					zap.Error(err),
+					zap.String("session_id", s.id),
+				)
+			}
+		}()
+	}
 
 	errCh := make(chan error)
 
@@ -160,7 +248,7 @@ func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) error {
 		}
 	}()
 
-	err := s.sendMsgToClient(&msgsvr.AksHelloGame{})
+	err = s.sendMsgToClient(&msgsvr.AksHelloGame{})
 	if err != nil {
 		return err
 	}
@@ -169,19 +257,121 @@ func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) error {
 	case err := <-errCh:
 		return err
 	case <-ctx.Done():
-		return ctx.Err()
+		return context.Cause(ctx)
 	}
 }
 
-func (p *Proxy) trackSession(s *session, add bool) {
+// SetAccessList sets the access list checked against the address of the clients that connect from now on, or allows
+// every client if l is nil.
+func (p *Proxy) SetAccessList(l *retroproxy.AccessList) {
+	p.accessList.Store(l)
+}
+
+// SetOutbounds sets the outbound proxies the connections to game servers go through, by account of the ticket, from
+// now on. They are direct if o is nil.
+func (p *Proxy) SetOutbounds(o *retroproxy.Outbounds) {
+	p.outbounds.Store(o)
+}
+
+// SetSourceAddrs sets the local addresses the connections to game servers are made from from now on, for the
+// tickets that don't carry one. The connections are made from any local address if s is nil.
+func (p *Proxy) SetSourceAddrs(s *retroproxy.SourceAddrs) {
+	p.sourceAddrs.Store(s)
+}
+
+// SetDialTimeout sets how long the proxy waits for connections to game servers to be established.
+func (p *Proxy) SetDialTimeout(d time.Duration) {
+	p.dialTimeout.Store(int64(d))
+}
+
+// SetMaxPktSize sets the maximum size in bytes of the packets read by the sessions that start afterwards, delimiter
+// included. Larger packets end the session.
+func (p *Proxy) SetMaxPktSize(n int) {
+	p.maxPktSize.Store(int64(n))
+}
+
+// SetLogSecrets sets whether packets are logged and recorded with the secrets they carry, like credentials and
+// tickets, instead of having them masked.
+func (p *Proxy) SetLogSecrets(v bool) {
+	p.logSecrets.Store(v)
+}
+
+func (p *Proxy) redactCliPkt(pkt string) string {
+	if p.logSecrets.Load() {
+		return pkt
+	}
+	return retroproxy.RedactCliPkt(pkt)
+}
+
+func (p *Proxy) redactSvrPkt(pkt string) string {
+	if p.logSecrets.Load() {
+		return pkt
+	}
+	return retroproxy.RedactSvrPkt(pkt)
+}
+
+// redactRawCliPkt redacts rawPkt, a packet sent by a client that ends with pkt once unwrapped.
+func (p *Proxy) redactRawCliPkt(rawPkt, pkt string) string {
+	return strings.TrimSuffix(rawPkt, pkt) + p.redactCliPkt(pkt)
+}
+
+// SetPublicAddr sets the public address of the proxy, so that it refuses the tickets issued for another game proxy.
+// Tickets are redeemed whatever address they were issued for if addr is empty, as by default.
+func (p *Proxy) SetPublicAddr(addr string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if add {
-		if p.sessions == nil {
-			p.sessions = make(map[*session]struct{})
-		}
-		p.sessions[s] = struct{}{}
-	} else {
-		delete(p.sessions, s)
+	p.public = addr
+}
+
+func (p *Proxy) publicAddr() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.public
+}
+
+// SetRecorder sets the recorder of the packets of the sessions started from now on, or disables the recording if r
+// is nil.
+func (p *Proxy) SetRecorder(r capture.Recorder) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recorder = r
+}
+
+// KickSession disconnects the session identified by id, and reports whether it was found.
+func (p *Proxy) KickSession(id string) bool {
+	return p.sessions.KickSession(id)
+}
+
+// KickAccount disconnects the sessions of account, and returns how many there were.
+func (p *Proxy) KickAccount(account string) int {
+	return p.sessions.KickAccount(account)
+}
+
+// Sessions returns the sessions currently handled by the proxy.
+func (p *Proxy) Sessions() []retroproxy.SessionInfo {
+	sessions := p.sessions.List()
+	infos := make([]retroproxy.SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, s.info())
 	}
+	return infos
+}
+
+// SetMetrics sets the metrics updated by the sessions started from now on, or disables them if m is nil.
+func (p *Proxy) SetMetrics(m *metrics.Metrics) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metrics = m
+}
+
+func (p *Proxy) trackSession(s *session, add bool) {
+	if !add {
+		p.sessions.Remove(s)
+		return
+	}
+	p.mu.Lock()
+	s.recorder = p.recorder
+	s.metrics = p.metrics
+	p.mu.Unlock()
+	p.sessions.Add(s)
 }
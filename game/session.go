@@ -1,11 +1,11 @@
 package game
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
 	"sync"
 	"time"
@@ -16,18 +16,36 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/codec"
+	"github.com/kralamoure/retroproxy/internal/relay"
+	"github.com/kralamoure/retroproxy/metrics"
 )
 
 type session struct {
+	id         string
 	proxy      *Proxy
+	recorder   capture.Recorder
+	metrics    *metrics.Metrics
 	clientConn *net.TCPConn
 	serverConn *net.TCPConn
+	// serverAddr is the address of the game server given by the ticket, which serverConn is connected to unless it
+	// goes through an outbound proxy.
+	serverAddr string
+	clientWr   *codec.Writer
+	serverWr   *codec.Writer
+	cancel     context.CancelCauseFunc
 
 	ticket              retroproxy.Ticket
 	ticketCh            chan retroproxy.Ticket
 	connectedToServerCh chan struct{}
 
+	connectedAt time.Time
+	fromClient  relay.Traffic
+	fromServer  relay.Traffic
+
 	firstPkt bool
+	mu       sync.Mutex
 }
 
 func (s *session) connectToServer(ctx context.Context) error {
@@ -38,9 +56,28 @@ func (s *session) connectToServer(ctx context.Context) error {
 
 	select {
 	case t := <-s.ticketCh:
-		s.ticket = t
-
-		conn, err := net.DialTimeout("tcp4", net.JoinHostPort(t.Host, t.Port), 3*time.Second)
+		addr := net.JoinHostPort(t.Host, t.Port)
+		timeout := time.Duration(s.proxy.dialTimeout.Load())
+		outbound := s.proxy.outbounds.Load().For(t.Account)
+		var source netip.Addr
+		if t.SourceIP != "" {
+			ip, err := netip.ParseAddr(t.SourceIP)
+			if err != nil {
+				return fmt.Errorf("invalid source ip of ticket: %w", err)
+			}
+			source = ip
+		} else {
+			source = s.proxy.sourceAddrs.Load().For(t.Account)
+		}
+		dialStart := time.Now()
+		var conn net.Conn
+		var err error
+		if outbound != nil {
+			conn, err = outbound.Dial(ctx, s.proxy.network, addr, source, timeout)
+		} else {
+			conn, err = retroproxy.DialFrom(ctx, s.proxy.network, addr, source, timeout)
+		}
+		s.metrics.UpstreamDialed("game", time.Since(dialStart), err)
 		if err != nil {
 			return err
 		}
@@ -50,16 +87,22 @@ func (s *session) connectToServer(ctx context.Context) error {
 			return errors.New("could not assert server connection as a tcp connection")
 		}
 		s.proxy.logger.Info("connected to server",
-			zap.String("server_address", tcpConn.RemoteAddr().String()),
+			zap.String("server_address", addr),
 			zap.String("client_address", s.clientConn.RemoteAddr().String()),
+			zap.String("local_address", tcpConn.LocalAddr().String()),
+			zap.Stringer("outbound_proxy", outbound),
 		)
+		s.mu.Lock()
 		s.serverConn = tcpConn
+		s.serverAddr = addr
+		s.mu.Unlock()
+		s.serverWr = codec.NewWriter(tcpConn, codec.Client, codec.DefaultWriteTimeout)
 		close(s.connectedToServerCh)
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			err = s.receivePktsFromServer(ctx)
+			err := s.receivePktsFromServer(ctx)
 			if err != nil {
 				select {
 				case errCh <- err:
@@ -80,16 +123,13 @@ func (s *session) connectToServer(ctx context.Context) error {
 }
 
 func (s *session) receivePktsFromServer(ctx context.Context) error {
-	rd := bufio.NewReader(s.serverConn)
+	rd := codec.NewReader(s.serverConn, codec.Server, int(s.proxy.maxPktSize.Load()))
 	for {
-		pkt, err := rd.ReadString('\x00')
+		pkt, err := rd.ReadPkt()
 		if err != nil {
-			return err
-		}
-		pkt = strings.TrimSuffix(pkt, "\x00")
-		if pkt == "" {
-			continue
+			return fmt.Errorf("could not read from server: %w", err)
 		}
+		s.fromServer.Add(pkt)
 		err = s.handlePktFromServer(ctx, pkt)
 		if err != nil {
 			return err
@@ -98,16 +138,13 @@ func (s *session) receivePktsFromServer(ctx context.Context) error {
 }
 
 func (s *session) receivePktsFromClient(ctx context.Context) error {
-	rd := bufio.NewReader(s.clientConn)
+	rd := codec.NewReader(s.clientConn, codec.Client, int(s.proxy.maxPktSize.Load()))
 	for {
-		pkt, err := rd.ReadString('\x00')
+		pkt, err := rd.ReadPkt()
 		if err != nil {
-			return err
-		}
-		pkt = strings.TrimSuffix(pkt, "\n\x00")
-		if pkt == "" {
-			continue
+			return fmt.Errorf("could not read from client: %w", err)
 		}
+		s.fromClient.Add(pkt)
 		err = s.handlePktFromClient(ctx, pkt)
 		s.firstPkt = false
 		if err != nil {
@@ -120,46 +157,32 @@ func (s *session) handlePktFromServer(ctx context.Context, packet string) error
 	id, ok := retroproto.MsgSvrIdByPkt(packet)
 	name, _ := retroproto.MsgSvrNameByID(id)
 	s.proxy.logger.Info("received packet from server",
-		zap.String("server_address", s.serverConn.RemoteAddr().String()),
+		zap.String("server_address", s.serverAddr),
 		zap.String("client_address", s.clientConn.RemoteAddr().String()),
 		zap.String("message_name", name),
-		zap.String("packet", packet),
+		zap.String("packet", s.proxy.redactSvrPkt(packet)),
 	)
+	s.record(capture.FromServer, name, packet, packet)
 	if ok {
 		switch id {
 		case retroproto.AksHelloGame:
-			err := s.sendMsgToServer(&msgcli.AccountSendTicket{Ticket: s.ticket.Original})
+			err := s.sendMsgToServer(&msgcli.AccountSendTicket{Ticket: s.Ticket().Original})
 			if err != nil {
 				return err
 			}
 			return nil
-		case retroproto.GameMovement:
-			extra := strings.TrimPrefix(packet, string(id))
-
-			msg := &msgsvr.GameMovement{}
-			err := msg.Deserialize(extra)
-			if err != nil {
-				return err
-			}
-
-			for _, sprite := range msg.Sprites {
-				if sprite.Fight {
-					continue
-				}
-				if sprite.Type < 1 {
-					continue
-				}
-				s.proxy.logger.Debug("character spotted",
-					zap.String("character_name", sprite.Character.Name),
-					zap.Int("character_level", sprite.Character.Level),
-				)
-			}
 		}
 	}
 
-	s.sendPktToClient(packet)
-
-	return nil
+	out := &Packet{Data: packet}
+	err := s.proxy.handlers.ServerPkt(ctx, s, id, out)
+	if err != nil {
+		return err
+	}
+	if out.Dropped() {
+		return nil
+	}
+	return s.sendPktToClient(out.Data)
 }
 
 func (s *session) handlePktFromClient(ctx context.Context, rawPacket string) error {
@@ -184,9 +207,10 @@ func (s *session) handlePktFromClient(ctx context.Context, rawPacket string) err
 	s.proxy.logger.Info("received packet from client",
 		zap.String("client_address", s.clientConn.RemoteAddr().String()),
 		zap.String("message_name", name),
-		zap.String("packet", packet),
-		zap.String("raw_packet", rawPacket),
+		zap.String("packet", s.proxy.redactCliPkt(packet)),
+		zap.String("raw_packet", s.proxy.redactRawCliPkt(rawPacket, packet)),
 	)
+	s.record(capture.FromClient, name, rawPacket, packet)
 	if s.firstPkt && !ok {
 		return errors.New("invalid first packet")
 	}
@@ -203,14 +227,59 @@ func (s *session) handlePktFromClient(ctx context.Context, rawPacket string) err
 				return err
 			}
 
-			t, ok := s.proxy.storer.UseTicket(msg.Ticket)
-			if !ok {
-				err := s.sendMsgToClient(&msgsvr.AccountTicketResponseError{})
+			t, err := s.proxy.storer.UseTicket(ctx, msg.Ticket)
+			if err != nil {
+				err2 := s.sendMsgToClient(&msgsvr.AccountTicketResponseError{})
+				if err2 != nil {
+					return err2
+				}
+				if errors.Is(err, retroproxy.ErrTicketNotFound) {
+					s.metrics.TicketNotFound()
+					return err
+				}
+				return fmt.Errorf("could not use ticket: %w", err)
+			}
+
+			if addr := s.proxy.publicAddr(); addr != "" && t.GameAddr != "" && t.GameAddr != addr {
+				// The ticket is put back for the game proxy it was issued for. Without this, a sealed ticket could be
+				// redeemed again at each game proxy sharing the key, since they don't know which ones were redeemed.
+				err := s.proxy.storer.SetTicket(ctx, msg.Ticket, t)
+				if err != nil {
+					s.proxy.logger.Warn("could not put back ticket issued for another game proxy",
+						zap.Error(err),
+						zap.String("client_address", s.clientConn.RemoteAddr().String()),
+					)
+				}
+				err = s.sendMsgToClient(&msgsvr.AccountTicketResponseError{})
 				if err != nil {
 					return err
 				}
-				return errors.New("ticket not found")
+				return fmt.Errorf("ticket was issued for another game proxy: %s", t.GameAddr)
+			}
+
+			if s.proxy.bindTicketIP && t.ClientIP != "" {
+				addr, ok := s.clientConn.RemoteAddr().(*net.TCPAddr)
+				if !ok || addr.IP.String() != t.ClientIP {
+					// The ticket is put back, so that a client guessing it doesn't keep its owner from redeeming it.
+					err := s.proxy.storer.SetTicket(ctx, msg.Ticket, t)
+					if err != nil {
+						s.proxy.logger.Warn("could not put back ticket redeemed from another ip address",
+							zap.Error(err),
+							zap.String("client_address", s.clientConn.RemoteAddr().String()),
+						)
+					}
+					err = s.sendMsgToClient(&msgsvr.AccountTicketResponseError{})
+					if err != nil {
+						return err
+					}
+					return fmt.Errorf("ticket was issued to another ip address: %s", t.ClientIP)
+				}
 			}
+			s.metrics.TicketRedeemed()
+
+			s.mu.Lock()
+			s.ticket = t
+			s.mu.Unlock()
 
 			select {
 			case s.ticketCh <- t:
@@ -225,8 +294,79 @@ func (s *session) handlePktFromClient(ctx context.Context, rawPacket string) err
 	case <-ctx.Done():
 		return ctx.Err()
 	}
-	s.sendPktToServer(rawPacket)
-	return nil
+
+	out := &Packet{Data: packet}
+	err := s.proxy.handlers.ClientPkt(ctx, s, id, out)
+	if err != nil {
+		return err
+	}
+	if out.Dropped() {
+		return nil
+	}
+	if out.Data != packet {
+		rawPacket = strings.TrimSuffix(rawPacket, packet) + out.Data
+	}
+	return s.sendPktToServer(rawPacket)
+}
+
+func (s *session) ClientAddr() net.Addr {
+	return s.clientConn.RemoteAddr()
+}
+
+func (s *session) ServerAddr() net.Addr {
+	return s.serverConn.RemoteAddr()
+}
+
+func (s *session) UpstreamAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serverAddr
+}
+
+func (s *session) Ticket() retroproxy.Ticket {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ticket
+}
+
+func (s *session) info() retroproxy.SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := retroproxy.SessionInfo{
+		Id:          s.id,
+		ClientAddr:  s.clientConn.RemoteAddr().String(),
+		Account:     s.ticket.Account,
+		ServerId:    s.ticket.ServerId,
+		ConnectedAt: s.connectedAt,
+		FromClient:  s.fromClient.Load(),
+		FromServer:  s.fromServer.Load(),
+	}
+	info.ServerAddr = s.serverAddr
+	return info
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+func (s *session) Account() string {
+	return s.Ticket().Account
+}
+
+func (s *session) WarnShutdown() error {
+	return s.sendMsgToClient(&msgsvr.AksServerWillDisconnect{})
+}
+
+func (s *session) Disconnect(cause error) {
+	s.cancel(cause)
+}
+
+func (s *session) SendPktToClient(pkt string) error {
+	return s.sendPktToClient(pkt)
+}
+
+func (s *session) SendPktToServer(pkt string) error {
+	return s.sendPktToServer(pkt)
 }
 
 func (s *session) sendMsgToServer(msg retroproto.MsgCli) error {
@@ -234,8 +374,7 @@ func (s *session) sendMsgToServer(msg retroproto.MsgCli) error {
 	if err != nil {
 		return err
 	}
-	s.sendPktToServer(fmt.Sprint(msg.MessageId(), pkt))
-	return nil
+	return s.sendPktToServer(fmt.Sprint(msg.MessageId(), pkt))
 }
 
 func (s *session) sendMsgToClient(msg retroproto.MsgSvr) error {
@@ -243,11 +382,10 @@ func (s *session) sendMsgToClient(msg retroproto.MsgSvr) error {
 	if err != nil {
 		return err
 	}
-	s.sendPktToClient(fmt.Sprint(msg.MessageId(), pkt))
-	return nil
+	return s.sendPktToClient(fmt.Sprint(msg.MessageId(), pkt))
 }
 
-func (s *session) sendPktToServer(rawPacket string) {
+func (s *session) sendPktToServer(rawPacket string) error {
 	packet := rawPacket
 
 	// unknownToken seems to wrap a base64 encoded string sent by the client as the prefix of some types of packet.
@@ -267,21 +405,59 @@ func (s *session) sendPktToServer(rawPacket string) {
 	id, _ := retroproto.MsgCliIdByPkt(packet)
 	name, _ := retroproto.MsgCliNameByID(id)
 	s.proxy.logger.Info("sent packet to server",
-		zap.String("server_address", s.serverConn.RemoteAddr().String()),
+		zap.String("server_address", s.serverAddr),
 		zap.String("message_name", name),
-		zap.String("packet", packet),
-		zap.String("raw_packet", rawPacket),
+		zap.String("packet", s.proxy.redactCliPkt(packet)),
+		zap.String("raw_packet", s.proxy.redactRawCliPkt(rawPacket, packet)),
 	)
-	fmt.Fprint(s.serverConn, rawPacket+"\n\x00")
+	err := s.serverWr.WritePkt(rawPacket)
+	if err != nil {
+		return fmt.Errorf("could not write to server: %w", err)
+	}
+	s.record(capture.ToServer, name, rawPacket, packet)
+	return nil
 }
 
-func (s *session) sendPktToClient(pkt string) {
+func (s *session) sendPktToClient(pkt string) error {
 	id, _ := retroproto.MsgSvrIdByPkt(pkt)
 	name, _ := retroproto.MsgSvrNameByID(id)
 	s.proxy.logger.Info("sent packet to client",
 		zap.String("client_address", s.clientConn.RemoteAddr().String()),
 		zap.String("message_name", name),
-		zap.String("packet", pkt),
+		zap.String("packet", s.proxy.redactSvrPkt(pkt)),
 	)
-	fmt.Fprint(s.clientConn, pkt+"\x00")
+	err := s.clientWr.WritePkt(pkt)
+	if err != nil {
+		return fmt.Errorf("could not write to client: %w", err)
+	}
+	s.record(capture.ToClient, name, pkt, pkt)
+	return nil
+}
+
+// record records rawPkt, which ends with pkt once unwrapped.
+func (s *session) record(dir capture.Direction, name, rawPkt, pkt string) {
+	s.metrics.Packet("game", string(dir), name, rawPkt)
+	if s.recorder == nil {
+		return
+	}
+	if dir == capture.FromClient || dir == capture.ToServer {
+		rawPkt = s.proxy.redactRawCliPkt(rawPkt, pkt)
+	} else {
+		rawPkt = s.proxy.redactSvrPkt(rawPkt)
+	}
+	err := s.recorder.Record(capture.Record{
+		Time:        time.Now(),
+		Proxy:       "game",
+		SessionId:   s.id,
+		Account:     s.Ticket().Account,
+		Direction:   dir,
+		MessageName: name,
+		Packet:      rawPkt,
+	})
+	if err != nil {
+		s.proxy.logger.Warn("could not record packet",
+			zap.Error(err),
+			zap.String("session_id", s.id),
+		)
+	}
 }
@@ -0,0 +1,93 @@
+// Package relay implements the parts of the login and game proxies that don't depend on the kind of server they relay
+// to.
+package relay
+
+import (
+	"context"
+	"sync"
+
+	"github.com/kralamoure/retroproto"
+)
+
+// Packet is a packet going through the handlers of a session.
+type Packet struct {
+	// Data is the packet, without its delimiter. Handlers may modify it to change what is forwarded.
+	Data string
+
+	dropped bool
+}
+
+// Drop prevents the packet from being forwarded and from going through the remaining handlers.
+func (p *Packet) Drop() {
+	p.dropped = true
+}
+
+func (p *Packet) Dropped() bool {
+	return p.dropped
+}
+
+// Handler handles a packet forwarded by a session of type S. Returning an error ends the session.
+type Handler[S any] func(ctx context.Context, s S, pkt *Packet) error
+
+type handler[S any] struct {
+	id string
+	h  Handler[S]
+}
+
+// Handlers are the handlers registered on a proxy whose sessions are of type S. The zero value has no handlers and is
+// ready to use.
+type Handlers[S any] struct {
+	server []handler[S]
+	client []handler[S]
+	mu     sync.RWMutex
+}
+
+// HandleServerPkt registers h for the packets of message id forwarded from the server to the client. An empty id
+// matches every packet.
+func (r *Handlers[S]) HandleServerPkt(id retroproto.MsgSvrId, h Handler[S]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.server = append(r.server, handler[S]{id: string(id), h: h})
+}
+
+// HandleClientPkt registers h for the packets of message id forwarded from the client to the server. An empty id
+// matches every packet.
+func (r *Handlers[S]) HandleClientPkt(id retroproto.MsgCliId, h Handler[S]) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.client = append(r.client, handler[S]{id: string(id), h: h})
+}
+
+// ServerPkt runs the handlers of the packets of message id sent by the server, in the order they were registered,
+// until one of them fails or drops pkt.
+func (r *Handlers[S]) ServerPkt(ctx context.Context, s S, id retroproto.MsgSvrId, pkt *Packet) error {
+	r.mu.RLock()
+	handlers := r.server
+	r.mu.RUnlock()
+	return run(ctx, handlers, s, string(id), pkt)
+}
+
+// ClientPkt runs the handlers of the packets of message id sent by the client, in the order they were registered,
+// until one of them fails or drops pkt.
+func (r *Handlers[S]) ClientPkt(ctx context.Context, s S, id retroproto.MsgCliId, pkt *Packet) error {
+	r.mu.RLock()
+	handlers := r.client
+	r.mu.RUnlock()
+	return run(ctx, handlers, s, string(id), pkt)
+}
+
+func run[S any](ctx context.Context, handlers []handler[S], s S, id string, pkt *Packet) error {
+	for _, v := range handlers {
+		if v.id != "" && v.id != id {
+			continue
+		}
+		err := v.h(ctx, s, pkt)
+		if err != nil {
+			return err
+		}
+		if pkt.dropped {
+			return nil
+		}
+	}
+	return nil
+}
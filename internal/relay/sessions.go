@@ -0,0 +1,136 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// shutdownPollInterval is how often Shutdown checks whether the sessions have ended.
+const shutdownPollInterval = 500 * time.Millisecond
+
+var (
+	// ErrKicked is the cause of the end of the sessions disconnected by KickSession and KickAccount.
+	ErrKicked = errors.New("kicked")
+	// ErrShutdown is the cause of the end of the sessions disconnected by Shutdown.
+	ErrShutdown = errors.New("proxy shut down")
+)
+
+// Session is a session tracked by Sessions.
+type Session interface {
+	comparable
+
+	ID() string
+	// Account is the account of the session, or an empty string if it isn't known yet.
+	Account() string
+	// WarnShutdown tells the client that the proxy is about to close.
+	WarnShutdown() error
+	// Disconnect ends the session with cause.
+	Disconnect(cause error)
+}
+
+// Sessions are the sessions currently handled by a proxy. The zero value is empty and ready to use.
+type Sessions[S Session] struct {
+	m  map[S]struct{}
+	mu sync.Mutex
+}
+
+// Add starts tracking s.
+func (r *Sessions[S]) Add(s S) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.m == nil {
+		r.m = make(map[S]struct{})
+	}
+	r.m[s] = struct{}{}
+}
+
+// Remove stops tracking s.
+func (r *Sessions[S]) Remove(s S) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.m, s)
+}
+
+// List returns the sessions tracked.
+func (r *Sessions[S]) List() []S {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]S, 0, len(r.m))
+	for s := range r.m {
+		list = append(list, s)
+	}
+	return list
+}
+
+// Len returns the number of sessions tracked.
+func (r *Sessions[S]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.m)
+}
+
+// KickSession disconnects the session identified by id, and reports whether it was found.
+func (r *Sessions[S]) KickSession(id string) bool {
+	for _, s := range r.List() {
+		if s.ID() == id {
+			s.Disconnect(ErrKicked)
+			return true
+		}
+	}
+	return false
+}
+
+// KickAccount disconnects the sessions of account, and returns how many there were.
+func (r *Sessions[S]) KickAccount(account string) int {
+	n := 0
+	for _, s := range r.List() {
+		if s.Account() == account {
+			s.Disconnect(ErrKicked)
+			n++
+		}
+	}
+	return n
+}
+
+// Shutdown warns the clients of the sessions that the proxy is about to close, and waits for their sessions to end.
+// If ctx is done first, the remaining sessions are disconnected and ctx.Err() is returned.
+func (r *Sessions[S]) Shutdown(ctx context.Context, logger *zap.Logger) error {
+	sessions := r.List()
+	logger.Info("shutting down",
+		zap.Int("sessions", len(sessions)),
+	)
+	for _, s := range sessions {
+		err := s.WarnShutdown()
+		if err != nil {
+			logger.Debug("could not warn client of shutdown",
+				zap.Error(err),
+				zap.String("session_id", s.ID()),
+			)
+		}
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		n := r.Len()
+		if n == 0 {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			for _, s := range r.List() {
+				s.Disconnect(ErrShutdown)
+			}
+			logger.Warn("sessions disconnected before their end",
+				zap.Int("sessions", n),
+			)
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
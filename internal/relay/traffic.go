@@ -0,0 +1,27 @@
+package relay
+
+import (
+	"sync/atomic"
+
+	"github.com/kralamoure/retroproxy"
+)
+
+// Traffic counts the packets relayed in one direction of a session. It's safe for concurrent use.
+type Traffic struct {
+	packets atomic.Uint64
+	bytes   atomic.Uint64
+}
+
+// Add counts pkt.
+func (t *Traffic) Add(pkt string) {
+	t.packets.Add(1)
+	t.bytes.Add(uint64(len(pkt)))
+}
+
+// Load returns the packets and bytes counted so far.
+func (t *Traffic) Load() retroproxy.Traffic {
+	return retroproxy.Traffic{
+		Packets: t.packets.Load(),
+		Bytes:   t.bytes.Load(),
+	}
+}
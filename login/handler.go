@@ -0,0 +1,105 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/kralamoure/retroproto"
+	"github.com/kralamoure/retroproto/msgcli"
+	"github.com/kralamoure/retroproto/msgsvr"
+
+	"github.com/kralamoure/retroproxy/internal/relay"
+)
+
+// Session is the view of a login session given to packet handlers.
+type Session interface {
+	ClientAddr() net.Addr
+	// ServerAddr is the remote address of the connection to the server, which is the address of the outbound proxy
+	// when the connection goes through one.
+	ServerAddr() net.Addr
+	// UpstreamAddr is the configured address of the login server the session is connected to, as host:port.
+	UpstreamAddr() string
+	// Username is the account username sent by the client, or an empty string if it didn't send it yet.
+	Username() string
+
+	// SendPktToClient injects a packet into the stream going to the client.
+	SendPktToClient(pkt string) error
+	// SendPktToServer injects a packet into the stream going to the server.
+	SendPktToServer(pkt string) error
+}
+
+// Packet is a packet going through the handlers of a session.
+type Packet = relay.Packet
+
+// Handler handles a packet forwarded by a session. Returning an error ends the session.
+type Handler = relay.Handler[Session]
+
+// HandleServerPkt registers h for the packets of message id forwarded from the server to the client. An empty id
+// matches every packet. Handlers run in the order they were registered.
+func (p *Proxy) HandleServerPkt(id retroproto.MsgSvrId, h Handler) {
+	p.handlers.HandleServerPkt(id, h)
+}
+
+// HandleClientPkt registers h for the packets of message id forwarded from the client to the server. An empty id
+// matches every packet. Handlers run in the order they were registered.
+func (p *Proxy) HandleClientPkt(id retroproto.MsgCliId, h Handler) {
+	p.handlers.HandleClientPkt(id, h)
+}
+
+// ForceAdmin is a Handler for AccountLoginSuccess packets that enables the admin mode of the client.
+func ForceAdmin(ctx context.Context, s Session, pkt *Packet) error {
+	msg := &msgsvr.AccountLoginSuccess{}
+	err := msg.Deserialize(strings.TrimPrefix(pkt.Data, string(retroproto.AccountLoginSuccess)))
+	if err != nil {
+		return err
+	}
+
+	msg.Authorized = true
+
+	extra, err := msg.Serialized()
+	if err != nil {
+		return err
+	}
+	pkt.Data = string(msg.MessageId()) + extra
+	return nil
+}
+
+// RewriteConfiguredPort is a Handler for AccountConfiguredPort packets that replaces the port the client connected to
+// with the port of the login server of the session, as if the client had connected to it directly.
+func (p *Proxy) RewriteConfiguredPort(ctx context.Context, s Session, pkt *Packet) error {
+	_, rawPort, err := net.SplitHostPort(s.UpstreamAddr())
+	if err != nil {
+		return fmt.Errorf("could not split server address: %w", err)
+	}
+	port, err := strconv.Atoi(rawPort)
+	if err != nil {
+		return fmt.Errorf("invalid server port: %w", err)
+	}
+	msg := msgcli.AccountConfiguredPort{Port: port}
+	extra, err := msg.Serialized()
+	if err != nil {
+		return err
+	}
+	pkt.Data = string(msg.MessageId()) + extra
+	return nil
+}
+
+// RewriteIdentity is a Handler for AccountSendIdentity packets that replaces the identity sent by the client with
+// one generated by the proxy, which stays the same for each account.
+func (p *Proxy) RewriteIdentity(ctx context.Context, s Session, pkt *Packet) error {
+	id, err := p.identity(s.Username())
+	if err != nil {
+		return err
+	}
+
+	msg := msgcli.AccountSendIdentity{Id: id}
+	extra, err := msg.Serialized()
+	if err != nil {
+		return err
+	}
+	pkt.Data = string(msg.MessageId()) + extra
+	return nil
+}
@@ -3,93 +3,168 @@ package login
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net"
-	"strconv"
+	"net/netip"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gofrs/uuid"
 	"go.uber.org/zap"
 
 	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/codec"
+	"github.com/kralamoure/retroproxy/internal/relay"
+	"github.com/kralamoure/retroproxy/metrics"
 )
 
 type Proxy struct {
-	logger     *zap.Logger
-	addr       *net.TCPAddr
-	serverAddr *net.TCPAddr
-	storer     retroproxy.Storer
-	forceAdmin bool
-
-	gameHost string
-	gamePort string
+	logger    *zap.Logger
+	network   string
+	addr      *net.TCPAddr
+	storer    retroproxy.ContextStorer
+	ticketDur time.Duration
+
+	upstreams           []*upstream // guarded by mu
+	resolver            *retroproxy.Resolver
+	outbounds           atomic.Pointer[retroproxy.Outbounds]
+	sourceAddrs         atomic.Pointer[retroproxy.SourceAddrs]
+	balancing           Balancing // guarded by mu
+	nextUpstream        atomic.Uint64
+	healthCheckInterval atomic.Int64 // time.Duration
+	gameAddr            string       // guarded by mu
+	gameHost            string       // guarded by mu
+	gamePort            string       // guarded by mu
+	accessList          atomic.Pointer[retroproxy.AccessList]
+
+	logSecrets   atomic.Bool
+	dialTimeout  atomic.Int64 // time.Duration
+	maxPktSize   atomic.Int64
+	shuttingDown atomic.Bool
+	draining     atomic.Bool
+	recorder     capture.Recorder
+	metrics      *metrics.Metrics
 
 	ln       *net.TCPListener
-	sessions map[*session]struct{}
+	sessions relay.Sessions[*session]
 	mu       sync.Mutex
 
+	handlers relay.Handlers[Session]
+
 	cache proxyCache
 }
 
 type proxyCache struct {
-	serverPort     int
 	uuidByUsername map[string]string // guarded by proxy mu
 }
 
-func NewProxy(addr, serverAddr, gamePublicAddr string, storer retroproxy.Storer, forceAdmin bool, logger *zap.Logger) (*Proxy, error) {
+// NewProxy returns a login proxy, which connects the clients to the first of serverAddrs that is healthy until set
+// otherwise with SetBalancing. It has no packet handlers: the built-in ForceAdmin, RewriteConfiguredPort and
+// RewriteIdentity are registered by the caller, each one if wanted.
+func NewProxy(network, addr string, serverAddrs []string, gamePublicAddr string, storer retroproxy.ContextStorer, ticketDur time.Duration, logger *zap.Logger) (*Proxy, error) {
 	if storer == nil {
 		return nil, errors.New("storer is nil")
 	}
+	if ticketDur <= 0 {
+		return nil, errors.New("ticket duration is not positive")
+	}
 
 	if logger == nil {
 		logger = zap.NewNop()
 	}
 
-	tcpAddr, err := net.ResolveTCPAddr("tcp4", addr)
+	tcpAddr, err := net.ResolveTCPAddr(network, addr)
 	if err != nil {
 		return nil, err
 	}
 
-	tcpServerAddr, err := net.ResolveTCPAddr("tcp4", serverAddr)
+	p := &Proxy{
+		logger:    logger,
+		network:   network,
+		addr:      tcpAddr,
+		storer:    storer,
+		ticketDur: ticketDur,
+		resolver:  retroproxy.NewResolver(retroproxy.DefaultResolveTTL),
+		cache: proxyCache{
+			uuidByUsername: make(map[string]string),
+		},
+	}
+	p.dialTimeout.Store(int64(retroproxy.DefaultDialTimeout))
+	p.maxPktSize.Store(codec.DefaultMaxPktSize)
+	p.healthCheckInterval.Store(int64(DefaultHealthCheckInterval))
+
+	err = p.SetServerAddrs(serverAddrs)
 	if err != nil {
 		return nil, err
 	}
-
-	_, serverPortStr, err := net.SplitHostPort(serverAddr)
+	err = p.SetGamePublicAddr(gamePublicAddr)
 	if err != nil {
 		return nil, err
 	}
+	return p, nil
+}
 
-	serverPort, err := strconv.Atoi(serverPortStr)
+// SetGamePublicAddr sets the address of the game proxy sent to the clients that select a game server from now on.
+func (p *Proxy) SetGamePublicAddr(addr string) error {
+	host, port, err := parseGamePublicAddr(addr)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	gameHost, gamePort, err := net.SplitHostPort(gamePublicAddr)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.gameAddr = addr
+	p.gameHost = host
+	p.gamePort = port
+	return nil
+}
+
+// CheckGamePublicAddr reports an error if SetGamePublicAddr would refuse addr.
+func CheckGamePublicAddr(addr string) error {
+	_, _, err := parseGamePublicAddr(addr)
+	return err
+}
+
+// parseGamePublicAddr returns the host and port of addr as sent to the clients.
+func parseGamePublicAddr(addr string) (host, port string, err error) {
+	host, port, err = net.SplitHostPort(addr)
 	if err != nil {
-		return nil, err
+		return "", "", err
 	}
+	if ip, err := netip.ParseAddr(host); err == nil && ip.Is6() {
+		if ip.Zone() != "" {
+			return "", "", fmt.Errorf("public address %q has a zone, which clients can't use", addr)
+		}
+		// The message joins the host and port with a colon, so IPv6 literals have to be bracketed.
+		host = "[" + host + "]"
+	}
+	return host, port, nil
+}
 
-	return &Proxy{
-		logger:     logger,
-		addr:       tcpAddr,
-		serverAddr: tcpServerAddr,
-		gameHost:   gameHost,
-		gamePort:   gamePort,
-		storer:     storer,
-		forceAdmin: forceAdmin,
-		cache: proxyCache{
-			serverPort:     serverPort,
-			uuidByUsername: make(map[string]string),
-		},
-	}, nil
+// SetAccessList sets the access list checked against the address of the clients that connect from now on, or allows
+// every client if l is nil.
+func (p *Proxy) SetAccessList(l *retroproxy.AccessList) {
+	p.accessList.Store(l)
+}
+
+// gamePublicAddr returns the public address of the game proxy as set, and its host and port as sent to the clients.
+func (p *Proxy) gamePublicAddr() (addr, host, port string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.gameAddr, p.gameHost, p.gamePort
 }
 
 func (p *Proxy) ListenAndServe(ctx context.Context) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
-	ln, err := net.ListenTCP("tcp4", p.addr)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ln, err := net.ListenTCP(p.network, p.addr)
 	if err != nil {
 		return err
 	}
@@ -102,13 +177,21 @@ func (p *Proxy) ListenAndServe(ctx context.Context) error {
 	p.logger.Info("listening",
 		zap.String("address", ln.Addr().String()),
 	)
+	p.mu.Lock()
 	p.ln = ln
+	p.mu.Unlock()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		p.checkHealth(ctx)
+	}()
 
 	errCh := make(chan error)
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		err := p.acceptLoop(ctx)
+		err := p.acceptLoop(ctx, ln)
 		if err != nil {
 			select {
 			case errCh <- err:
@@ -121,25 +204,52 @@ func (p *Proxy) ListenAndServe(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	case err := <-errCh:
+		if p.shuttingDown.Load() && errors.Is(err, net.ErrClosed) {
+			return nil
+		}
 		return err
 	}
 }
 
-func (p *Proxy) acceptLoop(ctx context.Context) error {
+// Shutdown stops the proxy gracefully: it stops accepting clients, warns the connected ones that the proxy is about to
+// close, and waits for their sessions to end. If ctx is done first, the remaining sessions are disconnected and
+// ctx.Err() is returned. ListenAndServe returns nil once every session has ended.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.shuttingDown.Store(true)
+
+	p.mu.Lock()
+	if p.ln != nil {
+		p.ln.Close()
+	}
+	p.mu.Unlock()
+
+	return p.sessions.Shutdown(ctx, p.logger)
+}
+
+func (p *Proxy) acceptLoop(ctx context.Context, ln *net.TCPListener) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
 	for {
-		conn, err := p.ln.AcceptTCP()
+		conn, err := ln.AcceptTCP()
 		if err != nil {
 			return err
 		}
+		if !p.accessList.Load().Allowed(conn.RemoteAddr()) {
+			p.logger.Info("client refused by access list",
+				zap.String("client_address", conn.RemoteAddr().String()),
+			)
+			conn.Close()
+			continue
+		}
 
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			err := p.handleClientConn(ctx, conn)
-			if err != nil && !(errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) || errors.Is(err, errEndOfService)) {
+			if err != nil && !(errors.Is(err, io.EOF) || errors.Is(err, context.Canceled) ||
+				errors.Is(err, errEndOfService) || errors.Is(err, relay.ErrKicked) || errors.Is(err, errDraining) ||
+				errors.Is(err, relay.ErrShutdown)) {
 				p.logger.Debug("error while handling client connection",
 					zap.Error(err),
 					zap.String("client_address", conn.RemoteAddr().String()),
@@ -149,46 +259,77 @@ func (p *Proxy) acceptLoop(ctx context.Context) error {
 	}
 }
 
-func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) error {
+func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) (err error) {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	sessionId, err := uuid.NewV4()
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
 	defer func() {
 		conn.Close()
 		p.logger.Info("client disconnected",
 			zap.String("client_address", conn.RemoteAddr().String()),
+			zap.String("session_id", sessionId.String()),
+			zap.NamedError("reason", err),
 		)
 	}()
 	p.logger.Info("client connected",
 		zap.String("client_address", conn.RemoteAddr().String()),
+		zap.String("session_id", sessionId.String()),
 	)
 
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
 	s := &session{
-		proxy:      p,
-		clientConn: conn,
-		serverIdCh: make(chan int),
+		id:          sessionId.String(),
+		proxy:       p,
+		clientConn:  conn,
+		clientWr:    codec.NewWriter(conn, codec.Server, codec.DefaultWriteTimeout),
+		serverIdCh:  make(chan int),
+		connectedAt: time.Now(),
+		cancel:      cancel,
 	}
 
 	p.trackSession(s, true)
 	defer p.trackSession(s, false)
 
-	serverConn, err := net.DialTimeout("tcp4", p.serverAddr.String(), 3*time.Second)
+	s.metrics.SessionStarted("login")
+	defer func() {
+		s.metrics.SessionEnded("login", time.Since(s.connectedAt))
+	}()
+
+	if s.recorder != nil {
+		defer func() {
+			err := s.recorder.EndSession(s.id)
+			if err != nil {
+				p.logger.Warn("could not end session recording",
+					zap.Error(err),
+					zap.String("session_id", s.id),
+				)
+			}
+		}()
+	}
+
+	if p.draining.Load() {
+		return s.refuseLogin(ctx)
+	}
+
+	s.sourceAddr = p.sourceAddrs.Load().Next()
+	tcpServerConn, serverAddr, err := p.dialServer(ctx, conn.RemoteAddr(), s.sourceAddr, s.metrics)
 	if err != nil {
 		return err
 	}
-	defer serverConn.Close()
-	tcpServerConn, ok := serverConn.(*net.TCPConn)
-	if !ok {
-		return errors.New("could not assert server connection as a tcp connection")
-	}
-	p.logger.Info("connected to server",
-		zap.String("client_address", conn.RemoteAddr().String()),
-		zap.String("server_address", tcpServerConn.RemoteAddr().String()),
-	)
+	defer tcpServerConn.Close()
+	s.mu.Lock()
 	s.serverConn = tcpServerConn
-
-	ctx, cancel := context.WithCancel(ctx)
-	defer cancel()
+	s.serverAddr = serverAddr
+	s.mu.Unlock()
+	s.serverWr = codec.NewWriter(tcpServerConn, codec.Client, codec.DefaultWriteTimeout)
 
 	errCh := make(chan error)
 
@@ -220,19 +361,117 @@ func (p *Proxy) handleClientConn(ctx context.Context, conn *net.TCPConn) error {
 	case err := <-errCh:
 		return err
 	case <-ctx.Done():
-		return ctx.Err()
+		return context.Cause(ctx)
 	}
 }
 
+// SetDialTimeout sets how long the proxy waits for connections to the login server to be established.
+func (p *Proxy) SetDialTimeout(d time.Duration) {
+	p.dialTimeout.Store(int64(d))
+}
+
+// SetMaxPktSize sets the maximum size in bytes of the packets read by the sessions that start afterwards, delimiter
+// included. Larger packets end the session.
+func (p *Proxy) SetMaxPktSize(n int) {
+	p.maxPktSize.Store(int64(n))
+}
+
+// SetLogSecrets sets whether packets are logged and recorded with the secrets they carry, like credentials and
+// tickets, instead of having them masked.
+func (p *Proxy) SetLogSecrets(v bool) {
+	p.logSecrets.Store(v)
+}
+
+func (p *Proxy) redactCliPkt(pkt string) string {
+	if p.logSecrets.Load() {
+		return pkt
+	}
+	return retroproxy.RedactCliPkt(pkt)
+}
+
+func (p *Proxy) redactSvrPkt(pkt string) string {
+	if p.logSecrets.Load() {
+		return pkt
+	}
+	return retroproxy.RedactSvrPkt(pkt)
+}
+
+// SetRecorder sets the recorder of the packets of the sessions started from now on, or disables the recording if r
+// is nil.
+func (p *Proxy) SetRecorder(r capture.Recorder) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.recorder = r
+}
+
+// SetDraining sets whether the proxy is draining. A draining proxy refuses new logins with a maintenance message, while
+// the sessions already started carry on.
+func (p *Proxy) SetDraining(v bool) {
+	if p.draining.Swap(v) != v {
+		p.logger.Info("draining changed",
+			zap.Bool("draining", v),
+		)
+	}
+}
+
+func (p *Proxy) Draining() bool {
+	return p.draining.Load()
+}
+
+// KickSession disconnects the session identified by id, and reports whether it was found.
+func (p *Proxy) KickSession(id string) bool {
+	return p.sessions.KickSession(id)
+}
+
+// KickAccount disconnects the sessions of account, and returns how many there were.
+func (p *Proxy) KickAccount(account string) int {
+	return p.sessions.KickAccount(account)
+}
+
+// Sessions returns the sessions currently handled by the proxy.
+func (p *Proxy) Sessions() []retroproxy.SessionInfo {
+	sessions := p.sessions.List()
+	infos := make([]retroproxy.SessionInfo, 0, len(sessions))
+	for _, s := range sessions {
+		infos = append(infos, s.info())
+	}
+	return infos
+}
+
+// SetMetrics sets the metrics updated by the sessions started from now on, or disables them if m is nil.
+func (p *Proxy) SetMetrics(m *metrics.Metrics) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.metrics = m
+}
+
 func (p *Proxy) trackSession(s *session, add bool) {
+	if !add {
+		p.sessions.Remove(s)
+		return
+	}
+	p.mu.Lock()
+	s.recorder = p.recorder
+	s.metrics = p.metrics
+	p.mu.Unlock()
+	p.sessions.Add(s)
+}
+
+// identity returns the identity generated by the proxy for the account of username.
+func (p *Proxy) identity(username string) (string, error) {
+	v, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	if add {
-		if p.sessions == nil {
-			p.sessions = make(map[*session]struct{})
-		}
-		p.sessions[s] = struct{}{}
-	} else {
-		delete(p.sessions, s)
+
+	id, ok := p.cache.uuidByUsername[username]
+	if !ok {
+		id = v.String()
+		p.cache.uuidByUsername[username] = id
 	}
+
+	return id, nil
 }
@@ -1,37 +1,62 @@
 package login
 
 import (
-	"bufio"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/gofrs/uuid"
 	"github.com/kralamoure/retroproto"
+	"github.com/kralamoure/retroproto/enum"
 	"github.com/kralamoure/retroproto/msgcli"
 	"github.com/kralamoure/retroproto/msgsvr"
 	"go.uber.org/zap"
 
 	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/capture"
+	"github.com/kralamoure/retroproxy/codec"
+	"github.com/kralamoure/retroproxy/internal/relay"
+	"github.com/kralamoure/retroproxy/metrics"
 )
 
-var errEndOfService = errors.New("end of service")
+var (
+	errEndOfService = errors.New("end of service")
+	errDraining     = errors.New("login refused while draining")
+)
+
+// refuseLoginTimeout is how long a client of a draining proxy has to send its credentials before it's disconnected.
+const refuseLoginTimeout = 30 * time.Second
 
 type session struct {
+	id         string
 	proxy      *Proxy
+	recorder   capture.Recorder
+	metrics    *metrics.Metrics
 	clientConn *net.TCPConn
 	serverConn *net.TCPConn
+	clientWr   *codec.Writer
+	serverWr   *codec.Writer
 	serverIdCh chan int
+	cancel     context.CancelCauseFunc
 
-	username string
-}
+	connectedAt time.Time
+	fromClient  relay.Traffic
+	fromServer  relay.Traffic
 
-type msgOutCli interface {
-	MessageId() (id retroproto.MsgCliId)
-	Serialized() (extra string, err error)
+	// sourceAddr is the local address the connection to the login server is made from, or the zero address for any.
+	sourceAddr netip.Addr
+	// serverAddr is the configured address of the login server, which serverConn is connected to unless it goes
+	// through an outbound proxy.
+	serverAddr string
+
+	username string
+	serverId int
+	mu       sync.Mutex
 }
 
 type msgOutSvr interface {
@@ -40,16 +65,13 @@ type msgOutSvr interface {
 }
 
 func (s *session) receivePktsFromServer(ctx context.Context) error {
-	rd := bufio.NewReader(s.serverConn)
+	rd := codec.NewReader(s.serverConn, codec.Server, int(s.proxy.maxPktSize.Load()))
 	for {
-		pkt, err := rd.ReadString('\x00')
+		pkt, err := rd.ReadPkt()
 		if err != nil {
-			return err
-		}
-		pkt = strings.TrimSuffix(pkt, "\x00")
-		if pkt == "" {
-			continue
+			return fmt.Errorf("could not read from server: %w", err)
 		}
+		s.fromServer.Add(pkt)
 		err = s.handlePktFromServer(ctx, pkt)
 		if err != nil {
 			return err
@@ -58,47 +80,105 @@ func (s *session) receivePktsFromServer(ctx context.Context) error {
 }
 
 func (s *session) receivePktsFromClient(ctx context.Context) error {
-	rd := bufio.NewReader(s.clientConn)
+	rd := codec.NewReader(s.clientConn, codec.Client, int(s.proxy.maxPktSize.Load()))
 	for {
-		pkt, err := rd.ReadString('\x00')
+		pkt, err := rd.ReadPkt()
+		if err != nil {
+			return fmt.Errorf("could not read from client: %w", err)
+		}
+		s.fromClient.Add(pkt)
+		err = s.handlePktFromClient(ctx, pkt)
 		if err != nil {
 			return err
 		}
-		pkt = strings.TrimSuffix(pkt, "\n\x00")
-		if pkt == "" {
+	}
+}
+
+// refuseLogin greets the client like the login server would, then refuses its login with a maintenance message once
+// it sends its credentials.
+func (s *session) refuseLogin(ctx context.Context) error {
+	err := s.clientConn.SetReadDeadline(time.Now().Add(refuseLoginTimeout))
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.clientConn.Close()
+		case <-done:
+		}
+	}()
+
+	salt, err := randomSalt()
+	if err != nil {
+		return err
+	}
+	err = s.sendMsgToClient(&msgsvr.AksHelloConnect{Salt: salt})
+	if err != nil {
+		return err
+	}
+
+	rd := codec.NewReader(s.clientConn, codec.Client, int(s.proxy.maxPktSize.Load()))
+	for {
+		pkt, err := rd.ReadPkt()
+		if err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return fmt.Errorf("could not read from client: %w", err)
+		}
+		s.fromClient.Add(pkt)
+		id, _ := retroproxy.CliMsgIdByPkt(pkt)
+		name, _ := retroproto.MsgCliNameByID(id)
+		s.proxy.logger.Info("received packet from client",
+			zap.String("client_address", s.clientConn.RemoteAddr().String()),
+			zap.String("message_name", name),
+			zap.String("packet", s.proxy.redactCliPkt(pkt)),
+		)
+		s.record(capture.FromClient, name, pkt)
+		if id != retroproto.AccountCredential {
 			continue
 		}
-		err = s.handlePktFromClient(ctx, pkt)
+
+		err = s.sendMsgToClient(&msgsvr.AccountLoginError{
+			Reason: enum.AccountLoginErrorReason.MaintainAccount,
+		})
 		if err != nil {
 			return err
 		}
+		return errDraining
 	}
 }
 
+// randomSalt returns a salt like the ones the login server sends in AksHelloConnect.
+func randomSalt() (string, error) {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = letters[int(b[i])%len(letters)]
+	}
+	return string(b), nil
+}
+
 func (s *session) handlePktFromServer(ctx context.Context, pkt string) error {
 	id, ok := retroproto.MsgSvrIdByPkt(pkt)
 	name, _ := retroproto.MsgSvrNameByID(id)
 	s.proxy.logger.Info("received packet from server",
-		zap.String("server_address", s.serverConn.RemoteAddr().String()),
+		zap.String("server_address", s.serverAddr),
 		zap.String("client_address", s.clientConn.RemoteAddr().String()),
 		zap.String("message_name", name),
-		zap.String("packet", pkt),
+		zap.String("packet", s.proxy.redactSvrPkt(pkt)),
 	)
+	s.record(capture.FromServer, name, pkt)
 	if ok {
 		extra := strings.TrimPrefix(pkt, string(id))
 		switch id {
-		case retroproto.AccountLoginSuccess:
-			msg := &msgsvr.AccountLoginSuccess{}
-			err := msg.Deserialize(extra)
-			if err != nil {
-				return err
-			}
-
-			if s.proxy.forceAdmin {
-				msg.Authorized = true
-			}
-
-			return s.sendMsgToClient(msg)
 		case retroproto.AccountSelectServerError:
 			select {
 			case <-s.serverIdCh:
@@ -145,20 +225,58 @@ func (s *session) handlePktFromServer(ctx context.Context, pkt string) error {
 				} else {
 					t.Port = msg.Port
 				}
-			}
 
-			id, err := uuid.NewV4()
-			if err != nil {
-				return err
+				// The message is split on colons, which breaks bracketed IPv6 literals.
+				if strings.HasPrefix(extra, "[") {
+					addr, _, _ := strings.Cut(extra, ";")
+					t.Host, t.Port, err = net.SplitHostPort(addr)
+					if err != nil {
+						return err
+					}
+				}
 			}
 
+			t.Account = s.Username()
+			if addr, ok := s.proxy.sourceAddrs.Load().Lookup(t.Account); ok {
+				t.SourceIP = addr.String()
+				if addr != s.sourceAddr {
+					// The login connection is made from any local address if the pool is empty.
+					var loginSource string
+					if s.sourceAddr.IsValid() {
+						loginSource = s.sourceAddr.String()
+					}
+					s.proxy.logger.Info("game connection will be made from another address than the login",
+						zap.String("client_address", s.clientConn.RemoteAddr().String()),
+						zap.String("login_source_address", loginSource),
+						zap.String("game_source_address", t.SourceIP),
+					)
+				}
+			} else if s.sourceAddr.IsValid() {
+				t.SourceIP = s.sourceAddr.String()
+			}
+			gameAddr, gameHost, gamePort := s.proxy.gamePublicAddr()
+			t.GameAddr = gameAddr
 			t.IssuedAt = time.Now()
-			s.proxy.storer.SetTicket(id.String(), t)
+			t.ExpiresAt = t.IssuedAt.Add(s.proxy.ticketDur)
+			if addr, ok := s.clientConn.RemoteAddr().(*net.TCPAddr); ok {
+				t.ClientIP = addr.IP.String()
+			}
+			ticketId, err := retroproxy.IssueTicket(ctx, s.proxy.storer, t)
+			if err != nil {
+				err2 := s.sendMsgToClient(&msgsvr.AccountSelectServerError{
+					Reason: enum.AccountSelectServerErrorReason.Default,
+				})
+				if err2 != nil {
+					return err2
+				}
+				return fmt.Errorf("could not issue ticket: %w", err)
+			}
+			s.metrics.TicketIssued()
 
 			msg := &msgsvr.AccountSelectServerPlainSuccess{
-				Host:   s.proxy.gameHost,
-				Port:   s.proxy.gamePort,
-				Ticket: id.String(),
+				Host:   gameHost,
+				Port:   gamePort,
+				Ticket: ticketId,
 			}
 			err = s.sendMsgToClient(msg)
 			if err != nil {
@@ -168,18 +286,19 @@ func (s *session) handlePktFromServer(ctx context.Context, pkt string) error {
 		}
 	}
 
-	s.sendPktToClient(pkt)
-	return nil
+	_, _, err := s.forwardPktToClient(ctx, id, pkt)
+	return err
 }
 
 func (s *session) handlePktFromClient(ctx context.Context, pkt string) error {
-	id, ok := retroproto.MsgCliIdByPkt(pkt)
+	id, ok := retroproxy.CliMsgIdByPkt(pkt)
 	name, _ := retroproto.MsgCliNameByID(id)
 	s.proxy.logger.Info("received packet from client",
 		zap.String("client_address", s.clientConn.RemoteAddr().String()),
 		zap.String("message_name", name),
-		zap.String("packet", pkt),
+		zap.String("packet", s.proxy.redactCliPkt(pkt)),
 	)
+	s.record(capture.FromClient, name, pkt)
 
 	if ok {
 		extra := strings.TrimPrefix(pkt, string(id))
@@ -190,63 +309,124 @@ func (s *session) handlePktFromClient(ctx context.Context, pkt string) error {
 			if err != nil {
 				return err
 			}
+			s.mu.Lock()
 			s.username = msg.Username
+			s.mu.Unlock()
 		case retroproto.AccountSetServer:
-			s.sendPktToServer(pkt)
+			sent, ok, err := s.forwardPktToServer(ctx, id, pkt)
+			if err != nil || !ok {
+				return err
+			}
 
 			msg := &msgcli.AccountSetServer{}
-			err := msg.Deserialize(extra)
+			err = msg.Deserialize(strings.TrimPrefix(sent, string(id)))
 			if err != nil {
 				return err
 			}
 
+			s.mu.Lock()
+			s.serverId = msg.Id
+			s.mu.Unlock()
+
 			select {
 			case s.serverIdCh <- msg.Id:
 				return nil
 			case <-ctx.Done():
 				return ctx.Err()
 			}
-		case retroproto.AccountConfiguredPort:
-			return s.sendMsgToServer(msgcli.AccountConfiguredPort{Port: s.proxy.cache.serverPort})
-		case retroproto.AccountSendIdentity:
-			id, err := s.identity(ctx)
-			if err != nil {
-				return err
-			}
-			return s.sendMsgToServer(msgcli.AccountSendIdentity{Id: id})
 		}
 	}
 
-	s.sendPktToServer(pkt)
+	_, _, err := s.forwardPktToServer(ctx, id, pkt)
+	return err
+}
 
-	return nil
+// forwardPktToServer sends pkt to the server once it went through the client packet handlers of the proxy. It returns
+// the packet that was sent, or false if a handler dropped it.
+func (s *session) forwardPktToServer(ctx context.Context, id retroproto.MsgCliId, pkt string) (string, bool, error) {
+	out := &Packet{Data: pkt}
+	err := s.proxy.handlers.ClientPkt(ctx, s, id, out)
+	if err != nil {
+		return "", false, err
+	}
+	if out.Dropped() {
+		return "", false, nil
+	}
+	return out.Data, true, s.sendPktToServer(out.Data)
 }
 
-func (s *session) identity(ctx context.Context) (string, error) {
-	v, err := uuid.NewV4()
+// forwardPktToClient sends pkt to the client once it went through the server packet handlers of the proxy. It returns
+// the packet that was sent, or false if a handler dropped it.
+func (s *session) forwardPktToClient(ctx context.Context, id retroproto.MsgSvrId, pkt string) (string, bool, error) {
+	out := &Packet{Data: pkt}
+	err := s.proxy.handlers.ServerPkt(ctx, s, id, out)
 	if err != nil {
-		return "", err
+		return "", false, err
+	}
+	if out.Dropped() {
+		return "", false, nil
 	}
+	return out.Data, true, s.sendPktToClient(out.Data)
+}
 
-	s.proxy.mu.Lock()
-	defer s.proxy.mu.Unlock()
+func (s *session) ClientAddr() net.Addr {
+	return s.clientConn.RemoteAddr()
+}
 
-	id, ok := s.proxy.cache.uuidByUsername[s.username]
-	if !ok {
-		id = v.String()
-		s.proxy.cache.uuidByUsername[s.username] = id
-	}
+func (s *session) ServerAddr() net.Addr {
+	return s.serverConn.RemoteAddr()
+}
 
-	return id, nil
+func (s *session) UpstreamAddr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.serverAddr
 }
 
-func (s *session) sendMsgToServer(msg msgOutCli) error {
-	pkt, err := msg.Serialized()
-	if err != nil {
-		return err
+func (s *session) Username() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.username
+}
+
+func (s *session) info() retroproxy.SessionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	info := retroproxy.SessionInfo{
+		Id:          s.id,
+		ClientAddr:  s.clientConn.RemoteAddr().String(),
+		Account:     s.username,
+		ServerId:    s.serverId,
+		ConnectedAt: s.connectedAt,
+		FromClient:  s.fromClient.Load(),
+		FromServer:  s.fromServer.Load(),
 	}
-	s.sendPktToServer(fmt.Sprint(msg.MessageId(), pkt))
-	return nil
+	info.ServerAddr = s.serverAddr
+	return info
+}
+
+func (s *session) ID() string {
+	return s.id
+}
+
+func (s *session) Account() string {
+	return s.Username()
+}
+
+func (s *session) WarnShutdown() error {
+	return s.sendMsgToClient(&msgsvr.AksServerWillDisconnect{})
+}
+
+func (s *session) Disconnect(cause error) {
+	s.cancel(cause)
+}
+
+func (s *session) SendPktToClient(pkt string) error {
+	return s.sendPktToClient(pkt)
+}
+
+func (s *session) SendPktToServer(pkt string) error {
+	return s.sendPktToServer(pkt)
 }
 
 func (s *session) sendMsgToClient(msg msgOutSvr) error {
@@ -254,28 +434,64 @@ func (s *session) sendMsgToClient(msg msgOutSvr) error {
 	if err != nil {
 		return err
 	}
-	s.sendPktToClient(fmt.Sprint(msg.MessageId(), pkt))
-	return nil
+	return s.sendPktToClient(fmt.Sprint(msg.MessageId(), pkt))
 }
 
-func (s *session) sendPktToServer(pkt string) {
-	id, _ := retroproto.MsgCliIdByPkt(pkt)
+func (s *session) sendPktToServer(pkt string) error {
+	id, _ := retroproxy.CliMsgIdByPkt(pkt)
 	name, _ := retroproto.MsgCliNameByID(id)
 	s.proxy.logger.Info("sent packet to server",
-		zap.String("server_address", s.serverConn.RemoteAddr().String()),
+		zap.String("server_address", s.serverAddr),
 		zap.String("message_name", name),
-		zap.String("packet", pkt),
+		zap.String("packet", s.proxy.redactCliPkt(pkt)),
 	)
-	fmt.Fprint(s.serverConn, pkt+"\n\x00")
+	err := s.serverWr.WritePkt(pkt)
+	if err != nil {
+		return fmt.Errorf("could not write to server: %w", err)
+	}
+	s.record(capture.ToServer, name, pkt)
+	return nil
 }
 
-func (s *session) sendPktToClient(pkt string) {
+func (s *session) sendPktToClient(pkt string) error {
 	id, _ := retroproto.MsgSvrIdByPkt(pkt)
 	name, _ := retroproto.MsgSvrNameByID(id)
 	s.proxy.logger.Info("sent packet to client",
 		zap.String("client_address", s.clientConn.RemoteAddr().String()),
 		zap.String("message_name", name),
-		zap.String("packet", pkt),
+		zap.String("packet", s.proxy.redactSvrPkt(pkt)),
 	)
-	fmt.Fprint(s.clientConn, pkt+"\x00")
+	err := s.clientWr.WritePkt(pkt)
+	if err != nil {
+		return fmt.Errorf("could not write to client: %w", err)
+	}
+	s.record(capture.ToClient, name, pkt)
+	return nil
+}
+
+func (s *session) record(dir capture.Direction, name, pkt string) {
+	s.metrics.Packet("login", string(dir), name, pkt)
+	if s.recorder == nil {
+		return
+	}
+	if dir == capture.FromClient || dir == capture.ToServer {
+		pkt = s.proxy.redactCliPkt(pkt)
+	} else {
+		pkt = s.proxy.redactSvrPkt(pkt)
+	}
+	err := s.recorder.Record(capture.Record{
+		Time:        time.Now(),
+		Proxy:       "login",
+		SessionId:   s.id,
+		Account:     s.Username(),
+		Direction:   dir,
+		MessageName: name,
+		Packet:      pkt,
+	})
+	if err != nil {
+		s.proxy.logger.Warn("could not record packet",
+			zap.Error(err),
+			zap.String("session_id", s.id),
+		)
+	}
 }
@@ -0,0 +1,318 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kralamoure/retroproto"
+	"go.uber.org/zap"
+
+	"github.com/kralamoure/retroproxy"
+	"github.com/kralamoure/retroproxy/codec"
+	"github.com/kralamoure/retroproxy/metrics"
+)
+
+// DefaultHealthCheckInterval is how often the login servers are checked when there are several of them.
+const DefaultHealthCheckInterval = 10 * time.Second
+
+// Balancing is how the login server of a session is picked among the healthy ones.
+type Balancing int
+
+const (
+	// Priority picks the first healthy login server, in the order they were given.
+	Priority Balancing = iota
+	// RoundRobin picks the healthy login servers in turn.
+	RoundRobin
+)
+
+// ParseBalancing returns the Balancing named s, which is "priority" or "round-robin".
+func ParseBalancing(s string) (Balancing, error) {
+	switch s {
+	case "priority":
+		return Priority, nil
+	case "round-robin":
+		return RoundRobin, nil
+	default:
+		return 0, fmt.Errorf("invalid balancing: %s", s)
+	}
+}
+
+func (b Balancing) String() string {
+	switch b {
+	case Priority:
+		return "priority"
+	case RoundRobin:
+		return "round-robin"
+	default:
+		return fmt.Sprintf("Balancing(%d)", int(b))
+	}
+}
+
+// upstream is a login server the sessions can connect to. Its address is resolved on each connection.
+type upstream struct {
+	addr    string
+	healthy atomic.Bool
+}
+
+// SetServerAddrs sets the addresses of the login servers the sessions started from now on connect to. The servers
+// that were already set keep their health.
+func (p *Proxy) SetServerAddrs(addrs []string) error {
+	err := CheckServerAddrs(addrs)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	old := make(map[string]*upstream, len(p.upstreams))
+	for _, u := range p.upstreams {
+		old[u.addr] = u
+	}
+	p.mu.Unlock()
+
+	upstreams := make([]*upstream, len(addrs))
+	for i, addr := range addrs {
+		if u, ok := old[addr]; ok {
+			upstreams[i] = u
+			continue
+		}
+		u := &upstream{addr: addr}
+		u.healthy.Store(true)
+		upstreams[i] = u
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.upstreams = upstreams
+	return nil
+}
+
+// CheckServerAddrs reports an error if SetServerAddrs would refuse addrs.
+func CheckServerAddrs(addrs []string) error {
+	if len(addrs) == 0 {
+		return errors.New("no login server address")
+	}
+	for _, addr := range addrs {
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return fmt.Errorf("invalid login server address %q: %w", addr, err)
+		}
+		_, err = strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid login server address %q: invalid port %q", addr, port)
+		}
+	}
+	return nil
+}
+
+// SetBalancing sets how the login server of the sessions started from now on is picked.
+func (p *Proxy) SetBalancing(b Balancing) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.balancing = b
+}
+
+// SetOutbounds sets the outbound proxies the connections to login servers go through from now on. Since the account
+// of a session is only known once it's connected to a server, only the default one is used. They are direct if o is
+// nil.
+func (p *Proxy) SetOutbounds(o *retroproxy.Outbounds) {
+	p.outbounds.Store(o)
+}
+
+// SetSourceAddrs sets the local addresses the connections to login servers are made from from now on. Since the
+// account of a session is only known once it's connected to a server, they are picked in turn from the pool, and the
+// game connection of the session is made from the same address unless its account has its own. The servers then see
+// the login and game connections of such an account come from different addresses. The connections are made from any
+// local address if s is nil.
+func (p *Proxy) SetSourceAddrs(s *retroproxy.SourceAddrs) {
+	p.sourceAddrs.Store(s)
+}
+
+// SetResolveTTL sets how long the addresses of the login servers are kept once resolved, or disables keeping them if
+// d is not positive.
+func (p *Proxy) SetResolveTTL(d time.Duration) {
+	p.resolver.SetTTL(d)
+}
+
+// SetHealthCheckInterval sets how often the login servers are checked when there are several of them, or disables
+// the checks if d is not positive. It applies from the next check on.
+func (p *Proxy) SetHealthCheckInterval(d time.Duration) {
+	p.healthCheckInterval.Store(int64(d))
+}
+
+// candidates returns the login servers to try for a new session, in order: the healthy ones as picked by the
+// balancing, then the unhealthy ones as a last resort.
+func (p *Proxy) candidates() []*upstream {
+	p.mu.Lock()
+	upstreams := p.upstreams
+	balancing := p.balancing
+	p.mu.Unlock()
+
+	var healthy, unhealthy []*upstream
+	for _, u := range upstreams {
+		if u.healthy.Load() {
+			healthy = append(healthy, u)
+		} else {
+			unhealthy = append(unhealthy, u)
+		}
+	}
+	if balancing == RoundRobin && len(healthy) > 1 {
+		n := int(p.nextUpstream.Add(1) % uint64(len(healthy)))
+		healthy = append(healthy[n:len(healthy):len(healthy)], healthy[:n]...)
+	}
+	return append(healthy, unhealthy...)
+}
+
+// dialServer connects to the first login server of the candidates that accepts the connection, from source unless
+// it's the zero address, and returns its configured address. The servers that don't are marked unhealthy.
+func (p *Proxy) dialServer(ctx context.Context, clientAddr net.Addr, source netip.Addr, m *metrics.Metrics) (*net.TCPConn, string, error) {
+	// The account isn't known yet, so only the default outbound proxy can be used.
+	outbound := p.outbounds.Load().For("")
+	var errs []error
+	for _, u := range p.candidates() {
+		dialStart := time.Now()
+		conn, err := p.dial(ctx, u.addr, outbound, source)
+		m.UpstreamDialed("login", time.Since(dialStart), err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, "", err
+			}
+			p.logger.Warn("could not connect to server",
+				zap.Error(err),
+				zap.String("client_address", clientAddr.String()),
+				zap.String("server_address", u.addr),
+			)
+			p.setHealthy(u, false)
+			errs = append(errs, err)
+			continue
+		}
+		tcpConn, ok := conn.(*net.TCPConn)
+		if !ok {
+			conn.Close()
+			return nil, "", errors.New("could not assert server connection as a tcp connection")
+		}
+		// A server marked down by a failed dial is back as soon as a dial succeeds, without waiting for a health check.
+		p.setHealthy(u, true)
+		p.logger.Info("connected to server",
+			zap.String("client_address", clientAddr.String()),
+			zap.String("server_address", u.addr),
+			zap.String("remote_address", tcpConn.RemoteAddr().String()),
+			zap.String("local_address", tcpConn.LocalAddr().String()),
+			zap.Stringer("outbound_proxy", outbound),
+		)
+		return tcpConn, u.addr, nil
+	}
+	return nil, "", fmt.Errorf("could not connect to any login server: %w", errors.Join(errs...))
+}
+
+// dial connects to the login server at addr, through outbound unless it's nil, and from source unless it's the zero
+// address.
+func (p *Proxy) dial(ctx context.Context, addr string, outbound *retroproxy.Outbound, source netip.Addr) (net.Conn, error) {
+	timeout := time.Duration(p.dialTimeout.Load())
+	if outbound != nil {
+		return outbound.Dial(ctx, p.network, addr, source, timeout)
+	}
+	return p.resolver.Dial(ctx, p.network, addr, source, timeout)
+}
+
+// checkHealth checks the login servers periodically until ctx is done.
+func (p *Proxy) checkHealth(ctx context.Context) {
+	for {
+		d := time.Duration(p.healthCheckInterval.Load())
+		enabled := d > 0
+		if !enabled {
+			// Look again later in case the checks get enabled.
+			d = DefaultHealthCheckInterval
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d):
+		}
+		if !enabled {
+			continue
+		}
+
+		p.mu.Lock()
+		upstreams := p.upstreams
+		p.mu.Unlock()
+		if len(upstreams) < 2 {
+			continue
+		}
+
+		var wg sync.WaitGroup
+		for _, u := range upstreams {
+			u := u
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := p.checkUpstream(ctx, u)
+				if ctx.Err() != nil {
+					return
+				}
+				if err != nil {
+					p.logger.Debug("login server health check failed",
+						zap.Error(err),
+						zap.String("server_address", u.addr),
+					)
+				}
+				p.setHealthy(u, err == nil)
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+// checkUpstream connects to u and waits for the hello it sends to every new client.
+func (p *Proxy) checkUpstream(ctx context.Context, u *upstream) error {
+	// The checks are made from the first address of the pool, so that they pass the same firewalls as the sessions.
+	var source netip.Addr
+	if s := p.sourceAddrs.Load(); s != nil && len(s.Pool) > 0 {
+		source = s.Pool[0]
+	}
+	conn, err := p.dial(ctx, u.addr, p.outbounds.Load().For(""), source)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	err = conn.SetReadDeadline(time.Now().Add(time.Duration(p.dialTimeout.Load())))
+	if err != nil {
+		return err
+	}
+	pkt, err := codec.NewReader(conn, codec.Server, int(p.maxPktSize.Load())).ReadPkt()
+	if err != nil {
+		return fmt.Errorf("could not read hello: %w", err)
+	}
+	if !strings.HasPrefix(pkt, string(retroproto.AksHelloConnect)) {
+		return fmt.Errorf("unexpected packet instead of hello: %q", pkt)
+	}
+	return nil
+}
+
+func (p *Proxy) setHealthy(u *upstream, v bool) {
+	p.mu.Lock()
+	m := p.metrics
+	p.mu.Unlock()
+	m.UpstreamHealthy("login", u.addr, v)
+
+	if u.healthy.Swap(v) == v {
+		return
+	}
+	if v {
+		p.logger.Info("login server is healthy again",
+			zap.String("server_address", u.addr),
+		)
+	} else {
+		p.logger.Warn("login server is unhealthy",
+			zap.String("server_address", u.addr),
+		)
+	}
+}
@@ -0,0 +1,169 @@
+// Package metrics holds the Prometheus metrics of the proxies and ticket stores.
+//
+// The methods of Metrics do nothing when called on a nil *Metrics, so that metrics can be left disabled.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "retroproxy"
+
+type Metrics struct {
+	sessionsActive       *prometheus.GaugeVec
+	connectionsAccepted  *prometheus.CounterVec
+	sessionDuration      *prometheus.HistogramVec
+	upstreamDialFailures *prometheus.CounterVec
+	upstreamDialDuration *prometheus.HistogramVec
+	upstreamHealthy      *prometheus.GaugeVec
+	tickets              *prometheus.CounterVec
+	packets              *prometheus.CounterVec
+	bytes                *prometheus.CounterVec
+}
+
+// New returns Metrics registered to reg.
+func New(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		sessionsActive: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "sessions_active",
+			Help:      "Number of sessions currently handled.",
+		}, []string{"proxy"}),
+		connectionsAccepted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "connections_accepted_total",
+			Help:      "Number of client connections accepted.",
+		}, []string{"proxy"}),
+		sessionDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "session_duration_seconds",
+			Help:      "Duration of the sessions, from the client connection to its end.",
+			Buckets:   []float64{1, 5, 15, 60, 300, 900, 1800, 3600, 7200, 14400, 28800},
+		}, []string{"proxy"}),
+		upstreamDialFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_dial_failures_total",
+			Help:      "Number of connections to servers that could not be established.",
+		}, []string{"proxy"}),
+		upstreamDialDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "upstream_dial_duration_seconds",
+			Help:      "Time taken to establish connections to servers.",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2, 10),
+		}, []string{"proxy"}),
+		upstreamHealthy: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "upstream_healthy",
+			Help:      "Whether a server passed its last health check or connection (1) or not (0).",
+		}, []string{"proxy", "address"}),
+		tickets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tickets_total",
+			Help:      "Number of tickets by outcome: issued, redeemed, expired or not_found.",
+		}, []string{"outcome"}),
+		packets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_total",
+			Help:      "Number of packets by direction and message name.",
+		}, []string{"proxy", "direction", "message_name"}),
+		bytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packet_bytes_total",
+			Help:      "Number of bytes of the packets, without delimiters, by direction and message name.",
+		}, []string{"proxy", "direction", "message_name"}),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.sessionsActive,
+		m.connectionsAccepted,
+		m.sessionDuration,
+		m.upstreamDialFailures,
+		m.upstreamDialDuration,
+		m.upstreamHealthy,
+		m.tickets,
+		m.packets,
+		m.bytes,
+	} {
+		err := reg.Register(c)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// SessionStarted counts a client connection accepted by proxy.
+func (m *Metrics) SessionStarted(proxy string) {
+	if m == nil {
+		return
+	}
+	m.connectionsAccepted.WithLabelValues(proxy).Inc()
+	m.sessionsActive.WithLabelValues(proxy).Inc()
+}
+
+// SessionEnded counts the end of a session of proxy that lasted d.
+func (m *Metrics) SessionEnded(proxy string, d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.sessionsActive.WithLabelValues(proxy).Dec()
+	m.sessionDuration.WithLabelValues(proxy).Observe(d.Seconds())
+}
+
+// UpstreamDialed counts a connection of proxy to a server that took d, and failed if err is not nil.
+func (m *Metrics) UpstreamDialed(proxy string, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	if err != nil {
+		m.upstreamDialFailures.WithLabelValues(proxy).Inc()
+		return
+	}
+	m.upstreamDialDuration.WithLabelValues(proxy).Observe(d.Seconds())
+}
+
+// UpstreamHealthy sets whether the server of proxy at addr is healthy.
+func (m *Metrics) UpstreamHealthy(proxy, addr string, healthy bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if healthy {
+		v = 1
+	}
+	m.upstreamHealthy.WithLabelValues(proxy, addr).Set(v)
+}
+
+func (m *Metrics) TicketIssued() {
+	m.ticket("issued")
+}
+
+func (m *Metrics) TicketRedeemed() {
+	m.ticket("redeemed")
+}
+
+func (m *Metrics) TicketExpired() {
+	m.ticket("expired")
+}
+
+func (m *Metrics) TicketNotFound() {
+	m.ticket("not_found")
+}
+
+func (m *Metrics) ticket(outcome string) {
+	if m == nil {
+		return
+	}
+	m.tickets.WithLabelValues(outcome).Inc()
+}
+
+// Packet counts a packet of proxy going in direction.
+func (m *Metrics) Packet(proxy, direction, messageName, pkt string) {
+	if m == nil {
+		return
+	}
+	m.packets.WithLabelValues(proxy, direction, messageName).Inc()
+	m.bytes.WithLabelValues(proxy, direction, messageName).Add(float64(len(pkt)))
+}
@@ -0,0 +1,96 @@
+package retroproxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Namespace returns a ContextStorer keeping the tickets of r in namespace, so that the tickets issued in a namespace
+// can't be redeemed from another one. It lets several realms share a store. namespace must not contain a colon, which
+// separates it from the ids of the tickets. The ids of the empty namespace are left as they are, but it can't redeem
+// the tickets of the other namespaces either.
+func Namespace(r ContextStorer, namespace string) ContextStorer {
+	n := namespaced{r: r, namespace: namespace}
+	if _, ok := r.(Issuer); ok {
+		return namespacedIssuer{n}
+	}
+	return n
+}
+
+// namespaced prefixes the ids of the tickets with the namespace.
+type namespaced struct {
+	r         ContextStorer
+	namespace string
+}
+
+func (n namespaced) SetTicket(ctx context.Context, id string, t Ticket) error {
+	t.Realm = n.namespace
+	return n.r.SetTicket(ctx, n.key(id), t)
+}
+
+func (n namespaced) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	// Without a prefix, the id of a ticket of the empty namespace could be the key of a ticket of another one.
+	if n.namespace == "" && strings.Contains(id, ":") {
+		return Ticket{}, ErrTicketNotFound
+	}
+	t, err := n.r.UseTicket(ctx, n.key(id))
+	if err != nil {
+		return Ticket{}, err
+	}
+	if t.Realm != n.namespace {
+		return Ticket{}, ErrTicketNotFound
+	}
+	return t, nil
+}
+
+func (n namespaced) Tickets(ctx context.Context) (map[string]Ticket, error) {
+	all, err := ListTickets(ctx, n.r)
+	if err != nil {
+		return nil, err
+	}
+	tickets := make(map[string]Ticket)
+	for id, t := range all {
+		if t.Realm != n.namespace {
+			continue
+		}
+		id, _ = strings.CutPrefix(id, n.key(""))
+		tickets[id] = t
+	}
+	return tickets, nil
+}
+
+func (n namespaced) key(id string) string {
+	if n.namespace == "" {
+		return id
+	}
+	return n.namespace + ":" + id
+}
+
+// namespacedIssuer checks the namespace of the tickets it redeems instead, since the ids of an Issuer are its own.
+type namespacedIssuer struct {
+	namespaced
+}
+
+func (n namespacedIssuer) IssueTicket(ctx context.Context, t Ticket) (string, error) {
+	t.Realm = n.namespace
+	return n.r.(Issuer).IssueTicket(ctx, t)
+}
+
+func (n namespacedIssuer) SetTicket(ctx context.Context, id string, t Ticket) error {
+	if t.Realm != n.namespace {
+		return errors.New("ticket is not in the namespace")
+	}
+	return n.r.SetTicket(ctx, id, t)
+}
+
+func (n namespacedIssuer) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	t, err := n.r.UseTicket(ctx, id)
+	if err != nil {
+		return Ticket{}, err
+	}
+	if t.Realm != n.namespace {
+		return Ticket{}, ErrTicketNotFound
+	}
+	return t, nil
+}
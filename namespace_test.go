@@ -0,0 +1,140 @@
+package retroproxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNamespace(t *testing.T) {
+	ctx := context.Background()
+
+	storers := []struct {
+		name string
+		new  func(t *testing.T) ContextStorer
+	}{
+		{
+			name: "cache",
+			new: func(t *testing.T) ContextStorer {
+				return AdaptStorer(NewCache(nil))
+			},
+		},
+		{
+			name: "sealer",
+			new: func(t *testing.T) ContextStorer {
+				s, err := NewSealer([]byte("0123456789abcdef"), nil)
+				if err != nil {
+					t.Fatalf("NewSealer() error = %v", err)
+				}
+				return s
+			},
+		},
+	}
+	tests := []struct {
+		name    string
+		issueIn string
+		useIn   string
+		wantErr error
+	}{
+		{
+			name:    "same realm",
+			issueIn: "eu",
+			useIn:   "eu",
+		},
+		{
+			name:    "same empty realm",
+			issueIn: "",
+			useIn:   "",
+		},
+		{
+			name:    "another realm",
+			issueIn: "eu",
+			useIn:   "us",
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:    "empty realm redeeming another one",
+			issueIn: "eu",
+			useIn:   "",
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:    "realm redeeming the empty one",
+			issueIn: "",
+			useIn:   "eu",
+			wantErr: ErrTicketNotFound,
+		},
+	}
+	for _, storer := range storers {
+		for _, tt := range tests {
+			t.Run(storer.name+"/"+tt.name, func(t *testing.T) {
+				r := storer.new(t)
+				ticket := Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)}
+				id, err := IssueTicket(ctx, Namespace(r, tt.issueIn), ticket)
+				if err != nil {
+					t.Fatalf("IssueTicket() error = %v", err)
+				}
+
+				got, err := Namespace(r, tt.useIn).UseTicket(ctx, id)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Fatalf("UseTicket() error = %v, want %v", err, tt.wantErr)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("UseTicket() error = %v", err)
+				}
+				if got.Realm != tt.issueIn {
+					t.Fatalf("UseTicket() = %+v, want realm %q", got, tt.issueIn)
+				}
+			})
+		}
+	}
+}
+
+func TestNamespaceEmptyRealmRefusesPrefixedIds(t *testing.T) {
+	ctx := context.Background()
+	r := AdaptStorer(NewCache(nil))
+
+	// The key of a ticket of realm "eu" is "eu:" followed by its id, which the empty realm must not redeem.
+	_, err := IssueTicket(ctx, Namespace(r, "eu"), Ticket{ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("IssueTicket() error = %v", err)
+	}
+	tickets, err := ListTickets(ctx, r)
+	if err != nil {
+		t.Fatalf("ListTickets() error = %v", err)
+	}
+	for key := range tickets {
+		_, err := Namespace(r, "").UseTicket(ctx, key)
+		if !errors.Is(err, ErrTicketNotFound) {
+			t.Fatalf("UseTicket(%q) in the empty realm error = %v, want %v", key, err, ErrTicketNotFound)
+		}
+	}
+}
+
+func TestNamespaceTickets(t *testing.T) {
+	ctx := context.Background()
+	r := AdaptStorer(NewCache(nil))
+
+	ids := make(map[string]string)
+	for _, realm := range []string{"", "eu", "us"} {
+		id, err := IssueTicket(ctx, Namespace(r, realm), Ticket{ExpiresAt: time.Now().Add(time.Hour)})
+		if err != nil {
+			t.Fatalf("IssueTicket() in realm %q error = %v", realm, err)
+		}
+		ids[realm] = id
+	}
+
+	for realm, id := range ids {
+		tickets, err := ListTickets(ctx, Namespace(r, realm))
+		if err != nil {
+			t.Fatalf("ListTickets() of realm %q error = %v", realm, err)
+		}
+		if _, ok := tickets[id]; !ok || len(tickets) != 1 {
+			t.Fatalf("ListTickets() of realm %q = %v, want only %q", realm, tickets, id)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package retroproxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/kralamoure/retroproto"
+)
+
+// RedactedMask replaces the secrets of redacted packets.
+const RedactedMask = "[REDACTED]"
+
+// CliMsgIdByPkt returns the id of the message carried by pkt, a packet sent by a client, like
+// retroproto.MsgCliIdByPkt. AccountCredential is recognized by its shape first, since it has no prefix and its
+// username can start like the id of another message.
+func CliMsgIdByPkt(pkt string) (retroproto.MsgCliId, bool) {
+	if isCredentialPkt(pkt) {
+		return retroproto.AccountCredential, true
+	}
+	return retroproto.MsgCliIdByPkt(pkt)
+}
+
+// isCredentialPkt reports whether pkt has the shape of an AccountCredential packet: the username, a line feed, a '#',
+// the digit of the crypto method and the password hash.
+func isCredentialPkt(pkt string) bool {
+	username, hash, ok := strings.Cut(pkt, "\n")
+	return ok && username != "" && len(hash) >= 3 && hash[0] == '#' && hash[1] >= '0' && hash[1] <= '9'
+}
+
+// RedactCliPkt returns pkt, a packet sent by a client, with the secrets it carries masked: the password hash of
+// AccountCredential and the ticket of AccountSendTicket.
+func RedactCliPkt(pkt string) string {
+	id, ok := CliMsgIdByPkt(pkt)
+	if !ok {
+		return pkt
+	}
+
+	switch id {
+	case retroproto.AccountCredential:
+		// The hash follows the username line, a '#' and the digit of the crypto method.
+		i := strings.Index(pkt, "\n#")
+		if i == -1 || len(pkt) < i+3 {
+			return RedactedMask
+		}
+		return pkt[:i+3] + RedactedMask
+	case retroproto.AccountSendTicket:
+		return string(id) + RedactedMask
+	}
+	return pkt
+}
+
+// redactTicketId returns a fingerprint of the ticket id, which tells apart the logs of different tickets without
+// letting them be redeemed by whoever reads the logs.
+func redactTicketId(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:4])
+}
+
+// RedactSvrPkt returns pkt, a packet sent by a server, with the secrets it carries masked: the salt of
+// AksHelloConnect, the key of AccountKey and the tickets of AccountSelectServerSuccess and
+// AccountSelectServerPlainSuccess.
+func RedactSvrPkt(pkt string) string {
+	id, ok := retroproto.MsgSvrIdByPkt(pkt)
+	if !ok {
+		return pkt
+	}
+
+	switch id {
+	case retroproto.AksHelloConnect, retroproto.AccountKey:
+		return string(id) + RedactedMask
+	case retroproto.AccountSelectServerSuccess:
+		// The ticket follows the encoded ip address and port, 11 characters long.
+		const n = 11
+		extra := strings.TrimPrefix(pkt, string(id))
+		if len(extra) < n {
+			return string(id) + RedactedMask
+		}
+		return string(id) + extra[:n] + RedactedMask
+	case retroproto.AccountSelectServerPlainSuccess:
+		i := strings.LastIndex(pkt, ";")
+		if i == -1 {
+			return string(id) + RedactedMask
+		}
+		return pkt[:i+1] + RedactedMask
+	}
+	return pkt
+}
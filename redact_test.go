@@ -0,0 +1,100 @@
+package retroproxy
+
+import (
+	"testing"
+
+	"github.com/kralamoure/retroproto"
+)
+
+func TestRedactCliPkt(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  string
+		want string
+	}{
+		{
+			name: "credential",
+			pkt:  "alice\n#1abcdef0123",
+			want: "alice\n#1" + RedactedMask,
+		},
+		{
+			name: "credential of a username starting like a message id",
+			pkt:  "ATbob\n#1abcdef0123",
+			want: "ATbob\n#1" + RedactedMask,
+		},
+		{
+			name: "ticket",
+			pkt:  "ATabc123",
+			want: "AT" + RedactedMask,
+		},
+		{
+			name: "empty ticket",
+			pkt:  "AT",
+			want: "AT" + RedactedMask,
+		},
+		{
+			name: "version",
+			pkt:  "1.29.1",
+			want: "1.29.1",
+		},
+		{
+			name: "other message",
+			pkt:  "Af",
+			want: "Af",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RedactCliPkt(tt.pkt)
+			if got != tt.want {
+				t.Fatalf("RedactCliPkt(%q) = %q, want %q", tt.pkt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCliMsgIdByPkt(t *testing.T) {
+	tests := []struct {
+		name string
+		pkt  string
+		want retroproto.MsgCliId
+	}{
+		{
+			name: "credential",
+			pkt:  "alice\n#1abcdef0123",
+			want: retroproto.AccountCredential,
+		},
+		{
+			name: "credential of a username starting like a message id",
+			pkt:  "ATbob\n#1abcdef0123",
+			want: retroproto.AccountCredential,
+		},
+		{
+			name: "ticket",
+			pkt:  "ATabc123",
+			want: retroproto.AccountSendTicket,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := CliMsgIdByPkt(tt.pkt)
+			if !ok || got != tt.want {
+				t.Fatalf("CliMsgIdByPkt(%q) = %q, %v, want %q, true", tt.pkt, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactTicketId(t *testing.T) {
+	id := "c2b7a6f0-6d3e-4f2a-9d2b-1f0e8a7c5b3d"
+	got := redactTicketId(id)
+	if got == id || len(got) != 8 {
+		t.Fatalf("redactTicketId(%q) = %q, want an 8 character fingerprint", id, got)
+	}
+	if redactTicketId(id) != got {
+		t.Fatalf("redactTicketId(%q) is not stable", id)
+	}
+	if redactTicketId(id+"x") == got {
+		t.Fatalf("redactTicketId() gives the same fingerprint to different ids")
+	}
+}
@@ -0,0 +1,263 @@
+package retroproxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const ticketsPath = "/tickets/"
+
+// Remote is an implementation of ContextStorer for a ticket store served over HTTP by a StoreHandler, so that login
+// and game proxies running as separate processes can share tickets.
+type Remote struct {
+	logger  *zap.Logger
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewRemote(baseURL string, logger *zap.Logger) (*Remote, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported url scheme %q", u.Scheme)
+	}
+
+	return &Remote{
+		logger:  logger,
+		baseURL: strings.TrimSuffix(u.String(), "/"),
+		client:  &http.Client{Timeout: 3 * time.Second},
+	}, nil
+}
+
+// SetToken sets the bearer token sent to the store, which it requires if it was given one with StoreHandler.SetToken.
+func (r *Remote) SetToken(token string) {
+	r.token = token
+}
+
+func (r *Remote) SetTicket(ctx context.Context, id string, t Ticket) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	_, err = r.do(ctx, http.MethodPut, ticketsPath+url.PathEscape(id), b)
+	if err != nil {
+		return err
+	}
+	r.logger.Debug("ticket set",
+		zap.String("ticket_id", redactTicketId(id)),
+		zap.String("ticket", fmt.Sprintf("%+v", t)),
+	)
+	return nil
+}
+
+func (r *Remote) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	b, err := r.do(ctx, http.MethodPost, ticketsPath+url.PathEscape(id)+"/use", nil)
+	if err != nil {
+		return Ticket{}, err
+	}
+	var t Ticket
+	err = json.Unmarshal(b, &t)
+	if err != nil {
+		return Ticket{}, err
+	}
+	r.logger.Debug("ticket used",
+		zap.String("ticket_id", redactTicketId(id)),
+	)
+	return t, nil
+}
+
+// Tickets returns the tickets of the store waiting to be redeemed. They are keyed by fingerprints of their ids and
+// their original tickets are left out, since the store doesn't hand out what it takes to redeem them.
+func (r *Remote) Tickets(ctx context.Context) (map[string]Ticket, error) {
+	b, err := r.do(ctx, http.MethodGet, ticketsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	var tickets map[string]Ticket
+	err = json.Unmarshal(b, &tickets)
+	if err != nil {
+		return nil, err
+	}
+	return tickets, nil
+}
+
+func (r *Remote) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, r.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, ErrTicketNotFound
+	case resp.StatusCode == http.StatusNotImplemented:
+		return nil, ErrListNotSupported
+	case resp.StatusCode == http.StatusForbidden:
+		return nil, fmt.Errorf("%w: the store requires a token to list them", ErrListNotSupported)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return b, nil
+}
+
+// StoreHandler serves a ContextStorer over HTTP for Remote clients.
+type StoreHandler struct {
+	logger *zap.Logger
+	storer ContextStorer
+	token  string
+}
+
+func NewStoreHandler(storer ContextStorer, logger *zap.Logger) (*StoreHandler, error) {
+	if storer == nil {
+		return nil, errors.New("storer is nil")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &StoreHandler{
+		logger: logger,
+		storer: storer,
+	}, nil
+}
+
+// SetToken sets the bearer token the requests must carry, or lets any request through if token is empty. The
+// tickets can only be listed once a token is set.
+func (h *StoreHandler) SetToken(token string) {
+	h.token = token
+}
+
+func (h *StoreHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.URL.Path, ticketsPath) {
+		http.NotFound(w, req)
+		return
+	}
+	if !authorized(req, h.token) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	rest := strings.TrimPrefix(req.URL.Path, ticketsPath)
+
+	switch {
+	case rest == "":
+		if req.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if h.token == "" {
+			http.Error(w, "listing tickets requires a token", http.StatusForbidden)
+			return
+		}
+		tickets, err := ListTickets(req.Context(), h.storer)
+		if err != nil {
+			if errors.Is(err, ErrListNotSupported) {
+				http.Error(w, err.Error(), http.StatusNotImplemented)
+			} else {
+				h.serverError(w, err)
+			}
+			return
+		}
+		// Only what describes the tickets is listed: their ids and original tickets would let them be redeemed.
+		listed := make(map[string]Ticket, len(tickets))
+		for id, t := range tickets {
+			t.Original = ""
+			listed[redactTicketId(id)] = t
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(listed)
+		if err != nil {
+			h.logger.Debug("could not write response", zap.Error(err))
+		}
+	case path.Base(rest) == "use" && path.Dir(rest) != ".":
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		t, err := h.storer.UseTicket(req.Context(), path.Dir(rest))
+		if err != nil {
+			if errors.Is(err, ErrTicketNotFound) {
+				http.NotFound(w, req)
+			} else {
+				h.serverError(w, err)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		err = json.NewEncoder(w).Encode(t)
+		if err != nil {
+			h.logger.Debug("could not write response", zap.Error(err))
+		}
+	case !strings.Contains(rest, "/"):
+		if req.Method != http.MethodPut {
+			w.Header().Set("Allow", http.MethodPut)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		var t Ticket
+		err := json.NewDecoder(io.LimitReader(req.Body, 1<<16)).Decode(&t)
+		if err != nil {
+			http.Error(w, "invalid ticket", http.StatusBadRequest)
+			return
+		}
+		err = h.storer.SetTicket(req.Context(), rest, t)
+		if err != nil {
+			h.serverError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// authorized reports whether req carries token as a bearer token, or whether token is empty.
+func authorized(req *http.Request, token string) bool {
+	if token == "" {
+		return true
+	}
+	got, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func (h *StoreHandler) serverError(w http.ResponseWriter, err error) {
+	h.logger.Error("ticket store error", zap.Error(err))
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
@@ -0,0 +1,203 @@
+package retroproxy
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// failingStorer is a ContextStorer whose store is unavailable.
+type failingStorer struct{}
+
+var errUnavailable = errors.New("store unavailable")
+
+func (failingStorer) SetTicket(ctx context.Context, id string, t Ticket) error {
+	return errUnavailable
+}
+
+func (failingStorer) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	return Ticket{}, errUnavailable
+}
+
+func newTestRemote(t *testing.T, storer ContextStorer, serverToken, clientToken string) *Remote {
+	t.Helper()
+	h, err := NewStoreHandler(storer, nil)
+	if err != nil {
+		t.Fatalf("NewStoreHandler() error = %v", err)
+	}
+	h.SetToken(serverToken)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	r, err := NewRemote(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("NewRemote() error = %v", err)
+	}
+	r.SetToken(clientToken)
+	return r
+}
+
+func TestRemoteTickets(t *testing.T) {
+	ctx := context.Background()
+	ticket := Ticket{Host: "127.0.0.1", Port: "5556", Original: "abc", ExpiresAt: time.Now().Add(time.Hour)}
+
+	tests := []struct {
+		name        string
+		serverToken string
+		clientToken string
+		wantErr     bool
+	}{
+		{
+			name: "without a token",
+		},
+		{
+			name:        "with the token",
+			serverToken: "secret",
+			clientToken: "secret",
+		},
+		{
+			name:        "with another token",
+			serverToken: "secret",
+			clientToken: "guess",
+			wantErr:     true,
+		},
+		{
+			name:        "without the token",
+			serverToken: "secret",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRemote(t, AdaptStorer(NewCache(nil)), tt.serverToken, tt.clientToken)
+
+			err := r.SetTicket(ctx, "a", ticket)
+			if tt.wantErr {
+				if err == nil || errors.Is(err, ErrTicketNotFound) {
+					t.Fatalf("SetTicket() error = %v, want an authorization error", err)
+				}
+				_, err = r.UseTicket(ctx, "a")
+				if err == nil || errors.Is(err, ErrTicketNotFound) {
+					t.Fatalf("UseTicket() error = %v, want an authorization error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SetTicket() error = %v", err)
+			}
+
+			got, err := r.UseTicket(ctx, "a")
+			if err != nil {
+				t.Fatalf("UseTicket() error = %v", err)
+			}
+			if got.Host != ticket.Host || got.Port != ticket.Port || got.Original != ticket.Original {
+				t.Fatalf("UseTicket() = %+v, want %+v", got, ticket)
+			}
+			_, err = r.UseTicket(ctx, "a")
+			if !errors.Is(err, ErrTicketNotFound) {
+				t.Fatalf("UseTicket() a second time error = %v, want %v", err, ErrTicketNotFound)
+			}
+		})
+	}
+}
+
+func TestRemoteErrors(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		storer  ContextStorer
+		token   string
+		call    func(r *Remote) error
+		wantErr error
+	}{
+		{
+			name:   "unknown ticket",
+			storer: AdaptStorer(NewCache(nil)),
+			call: func(r *Remote) error {
+				_, err := r.UseTicket(ctx, "unknown")
+				return err
+			},
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:   "listing without a token",
+			storer: AdaptStorer(NewCache(nil)),
+			call: func(r *Remote) error {
+				_, err := r.Tickets(ctx)
+				return err
+			},
+			wantErr: ErrListNotSupported,
+		},
+		{
+			name:   "listing a storer that can't list",
+			storer: failingStorer{},
+			token:  "secret",
+			call: func(r *Remote) error {
+				_, err := r.Tickets(ctx)
+				return err
+			},
+			wantErr: ErrListNotSupported,
+		},
+		{
+			name:   "failing store on set",
+			storer: failingStorer{},
+			call: func(r *Remote) error {
+				return r.SetTicket(ctx, "a", Ticket{})
+			},
+		},
+		{
+			name:   "failing store on use",
+			storer: failingStorer{},
+			call: func(r *Remote) error {
+				_, err := r.UseTicket(ctx, "a")
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestRemote(t, tt.storer, tt.token, tt.token)
+			err := tt.call(r)
+			if err == nil {
+				t.Fatal("error = nil, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (errors.Is(err, ErrTicketNotFound) || errors.Is(err, ErrListNotSupported)) {
+				t.Fatalf("error = %v, want a server error", err)
+			}
+		})
+	}
+}
+
+func TestRemoteListing(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRemote(t, AdaptStorer(NewCache(nil)), "secret", "secret")
+
+	err := r.SetTicket(ctx, "a", Ticket{Host: "127.0.0.1", Original: "abc", ExpiresAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("SetTicket() error = %v", err)
+	}
+	tickets, err := r.Tickets(ctx)
+	if err != nil {
+		t.Fatalf("Tickets() error = %v", err)
+	}
+	if len(tickets) != 1 {
+		t.Fatalf("Tickets() = %v, want one ticket", tickets)
+	}
+	for id, ticket := range tickets {
+		if id == "a" {
+			t.Fatalf("Tickets() lists the id of the ticket")
+		}
+		if ticket.Original != "" {
+			t.Fatalf("Tickets() lists the original ticket %q", ticket.Original)
+		}
+		if ticket.Host != "127.0.0.1" {
+			t.Fatalf("Tickets() = %+v, want host 127.0.0.1", ticket)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package retroproxy
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Sealer is an implementation of ContextStorer and Issuer that doesn't keep tickets server-side. Tickets are encrypted
+// and signed with a shared key into the id handed to the client, so any proxy knowing the key can redeem them. Only
+// the ids of redeemed tickets are remembered, until the tickets expire, to prevent replays.
+type Sealer struct {
+	logger *zap.Logger
+	block  cipher.Block
+	macKey []byte
+
+	used map[string]struct{}
+	mu   sync.Mutex
+}
+
+// NewSealer returns a Sealer deriving its keys from key.
+//
+// The redeemed tickets are only remembered by the returned Sealer, so with several game proxies sharing key, a ticket
+// would be redeemable once by each of them. Game proxies given their public address with game.Proxy.SetPublicAddr
+// refuse the tickets issued for another one, which leaves a single game proxy able to redeem each ticket, unless
+// several of them share a public address.
+func NewSealer(key []byte, logger *zap.Logger) (*Sealer, error) {
+	if len(key) < 16 {
+		return nil, errors.New("key is shorter than 16 bytes")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	block, err := aes.NewCipher(deriveKey(key, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Sealer{
+		logger: logger,
+		block:  block,
+		macKey: deriveKey(key, "authentication"),
+		used:   make(map[string]struct{}),
+	}, nil
+}
+
+// IssueTicket seals t into its id. Tickets must have an expiry, so that redeemed ones can be forgotten.
+func (r *Sealer) IssueTicket(ctx context.Context, t Ticket) (string, error) {
+	if t.ExpiresAt.IsZero() {
+		return "", errors.New("ticket has no expiry")
+	}
+
+	plain, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+
+	b := make([]byte, aes.BlockSize+len(plain), aes.BlockSize+len(plain)+sha256.Size)
+	iv := b[:aes.BlockSize]
+	_, err = rand.Read(iv)
+	if err != nil {
+		return "", err
+	}
+	cipher.NewCTR(r.block, iv).XORKeyStream(b[aes.BlockSize:], plain)
+
+	mac := hmac.New(sha256.New, r.macKey)
+	mac.Write(b)
+	b = mac.Sum(b)
+
+	r.logger.Debug("ticket sealed")
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// SetTicket makes the ticket sealed into id redeemable again, as when a redeemed ticket is put back. It fails for ids
+// the Sealer didn't issue, since it can't store a ticket under them. t is ignored, the ticket being the one sealed into
+// id.
+func (r *Sealer) SetTicket(ctx context.Context, id string, t Ticket) error {
+	_, key, err := r.open(id)
+	if err != nil {
+		if errors.Is(err, ErrTicketNotFound) {
+			return errors.New("sealer can't store tickets by id")
+		}
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.used, key)
+	r.logger.Debug("ticket put back")
+	return nil
+}
+
+func (r *Sealer) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	t, key, err := r.open(id)
+	if err != nil {
+		return Ticket{}, err
+	}
+
+	if t.ExpiresAt.IsZero() || t.Expired(time.Now()) {
+		r.logger.Debug("sealed ticket expired")
+		return Ticket{}, ErrTicketNotFound
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.used[key]; ok {
+		r.logger.Debug("sealed ticket replayed")
+		return Ticket{}, ErrTicketNotFound
+	}
+	r.used[key] = struct{}{}
+	time.AfterFunc(time.Until(t.ExpiresAt), func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.used, key)
+	})
+
+	r.logger.Debug("ticket used")
+	return t, nil
+}
+
+// open returns the ticket sealed into id, along with the key remembering it once redeemed. It returns
+// ErrTicketNotFound if id wasn't issued by r.
+func (r *Sealer) open(id string) (Ticket, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(id)
+	if err != nil || len(b) < aes.BlockSize+sha256.Size {
+		return Ticket{}, "", ErrTicketNotFound
+	}
+
+	data, sum := b[:len(b)-sha256.Size], b[len(b)-sha256.Size:]
+	mac := hmac.New(sha256.New, r.macKey)
+	mac.Write(data)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		r.logger.Debug("ticket signature mismatch")
+		return Ticket{}, "", ErrTicketNotFound
+	}
+
+	plain := make([]byte, len(data)-aes.BlockSize)
+	cipher.NewCTR(r.block, data[:aes.BlockSize]).XORKeyStream(plain, data[aes.BlockSize:])
+
+	var t Ticket
+	err = json.Unmarshal(plain, &t)
+	if err != nil {
+		return Ticket{}, "", fmt.Errorf("could not decode sealed ticket: %w", err)
+	}
+	return t, string(sum), nil
+}
+
+func deriveKey(key []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,156 @@
+package retroproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSealer(t *testing.T) {
+	ctx := context.Background()
+	key := []byte("0123456789abcdef")
+
+	tests := []struct {
+		name    string
+		ticket  Ticket
+		modify  func(t *testing.T, id string) string
+		sealer  func(t *testing.T) *Sealer
+		wantErr error
+	}{
+		{
+			name:   "valid",
+			ticket: Ticket{Host: "127.0.0.1", Port: "5556", Original: "abc", ExpiresAt: time.Now().Add(time.Hour)},
+		},
+		{
+			name:   "tampered",
+			ticket: Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)},
+			modify: func(t *testing.T, id string) string {
+				b, err := base64.RawURLEncoding.DecodeString(id)
+				if err != nil {
+					t.Fatalf("could not decode id: %v", err)
+				}
+				b[len(b)/2] ^= 1
+				return base64.RawURLEncoding.EncodeToString(b)
+			},
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:   "truncated",
+			ticket: Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)},
+			modify: func(t *testing.T, id string) string {
+				return id[:len(id)-4]
+			},
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:   "not base64",
+			ticket: Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)},
+			modify: func(t *testing.T, id string) string {
+				return "not a sealed ticket!"
+			},
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:   "sealed with another key",
+			ticket: Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)},
+			sealer: func(t *testing.T) *Sealer {
+				s, err := NewSealer([]byte("fedcba9876543210"), nil)
+				if err != nil {
+					t.Fatalf("NewSealer() error = %v", err)
+				}
+				return s
+			},
+			wantErr: ErrTicketNotFound,
+		},
+		{
+			name:    "expired",
+			ticket:  Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(-time.Second)},
+			wantErr: ErrTicketNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewSealer(key, nil)
+			if err != nil {
+				t.Fatalf("NewSealer() error = %v", err)
+			}
+			id, err := s.IssueTicket(ctx, tt.ticket)
+			if err != nil {
+				t.Fatalf("IssueTicket() error = %v", err)
+			}
+			if tt.modify != nil {
+				id = tt.modify(t, id)
+			}
+			if tt.sealer != nil {
+				s = tt.sealer(t)
+			}
+
+			got, err := s.UseTicket(ctx, id)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("UseTicket() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UseTicket() error = %v", err)
+			}
+			if got.Host != tt.ticket.Host || got.Port != tt.ticket.Port || got.Original != tt.ticket.Original ||
+				!got.ExpiresAt.Equal(tt.ticket.ExpiresAt) {
+				t.Fatalf("UseTicket() = %+v, want %+v", got, tt.ticket)
+			}
+		})
+	}
+}
+
+func TestSealerReplay(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSealer([]byte("0123456789abcdef"), nil)
+	if err != nil {
+		t.Fatalf("NewSealer() error = %v", err)
+	}
+	ticket := Ticket{Host: "127.0.0.1", ExpiresAt: time.Now().Add(time.Hour)}
+	id, err := s.IssueTicket(ctx, ticket)
+	if err != nil {
+		t.Fatalf("IssueTicket() error = %v", err)
+	}
+
+	steps := []struct {
+		name    string
+		putBack bool
+		wantErr error
+	}{
+		{name: "first use"},
+		{name: "replay", wantErr: ErrTicketNotFound},
+		{name: "use once put back", putBack: true},
+		{name: "replay once put back", wantErr: ErrTicketNotFound},
+	}
+	for _, step := range steps {
+		if step.putBack {
+			err := s.SetTicket(ctx, id, ticket)
+			if err != nil {
+				t.Fatalf("%s: SetTicket() error = %v", step.name, err)
+			}
+		}
+		_, err := s.UseTicket(ctx, id)
+		if step.wantErr == nil && err != nil {
+			t.Fatalf("%s: UseTicket() error = %v", step.name, err)
+		}
+		if step.wantErr != nil && !errors.Is(err, step.wantErr) {
+			t.Fatalf("%s: UseTicket() error = %v, want %v", step.name, err, step.wantErr)
+		}
+	}
+}
+
+func TestSealerRefusesTicketsWithoutExpiry(t *testing.T) {
+	s, err := NewSealer([]byte("0123456789abcdef"), nil)
+	if err != nil {
+		t.Fatalf("NewSealer() error = %v", err)
+	}
+	_, err = s.IssueTicket(context.Background(), Ticket{Host: "127.0.0.1"})
+	if err == nil {
+		t.Fatal("IssueTicket() of a ticket without an expiry error = nil, want an error")
+	}
+}
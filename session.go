@@ -0,0 +1,24 @@
+package retroproxy
+
+import "time"
+
+// SessionInfo describes a session of a proxy at some point in time.
+type SessionInfo struct {
+	Id         string `json:"id"`
+	ClientAddr string `json:"client_address"`
+	// ServerAddr is empty until the proxy is connected to the server.
+	ServerAddr string `json:"server_address,omitempty"`
+	// Account is empty until the account username is known.
+	Account string `json:"account,omitempty"`
+	// ServerId is the game server selected by the client, or 0 if none was selected yet.
+	ServerId    int       `json:"server_id,omitempty"`
+	ConnectedAt time.Time `json:"connected_at"`
+	FromClient  Traffic   `json:"from_client"`
+	FromServer  Traffic   `json:"from_server"`
+}
+
+// Traffic counts the packets received from one end of a session, and their bytes without delimiters.
+type Traffic struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
@@ -0,0 +1,63 @@
+package retroproxy
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"sync/atomic"
+	"time"
+)
+
+// SourceAddrs picks the local address the connections to servers are made from, on hosts with several of them.
+type SourceAddrs struct {
+	// Pool is the addresses picked in turn for the connections of the accounts not in ByAccount. The connections are
+	// made from any local address if it's empty.
+	Pool []netip.Addr
+	// ByAccount is the address the connections of some accounts are made from.
+	ByAccount map[string]netip.Addr
+
+	next atomic.Uint64
+}
+
+// Lookup returns the address the connections of account are made from, if it has its own. It returns false if s is
+// nil.
+func (s *SourceAddrs) Lookup(account string) (netip.Addr, bool) {
+	if s == nil {
+		return netip.Addr{}, false
+	}
+	addr, ok := s.ByAccount[account]
+	return addr, ok
+}
+
+// Next returns the next address of the pool, or the zero address, meaning any local address, if the pool is empty or
+// s is nil.
+func (s *SourceAddrs) Next() netip.Addr {
+	if s == nil || len(s.Pool) == 0 {
+		return netip.Addr{}
+	}
+	n := (s.next.Add(1) - 1) % uint64(len(s.Pool))
+	return s.Pool[n]
+}
+
+// For returns the address the connections of account are made from: its own, or else the next address of the pool.
+func (s *SourceAddrs) For(account string) netip.Addr {
+	if addr, ok := s.Lookup(account); ok {
+		return addr
+	}
+	return s.Next()
+}
+
+// newDialer returns a dialer waiting up to timeout for connections, made from source unless it's the zero address.
+func newDialer(timeout time.Duration, source netip.Addr) *net.Dialer {
+	dialer := &net.Dialer{Timeout: timeout}
+	if source.IsValid() {
+		dialer.LocalAddr = net.TCPAddrFromAddrPort(netip.AddrPortFrom(source, 0))
+	}
+	return dialer
+}
+
+// DialFrom connects to addr from source, or from any local address if source is the zero address, waiting up to
+// timeout for the connection.
+func DialFrom(ctx context.Context, network, addr string, source netip.Addr, timeout time.Duration) (net.Conn, error) {
+	return newDialer(timeout, source).DialContext(ctx, network, addr)
+}
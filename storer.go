@@ -2,25 +2,109 @@ package retroproxy
 
 import (
 	"context"
-	"time"
+	"errors"
+
+	"github.com/gofrs/uuid"
+)
+
+var (
+	ErrTicketNotFound   = errors.New("ticket not found")
+	ErrListNotSupported = errors.New("storer cannot list tickets")
 )
 
+// Storer stores tickets until they are used or expire.
 type Storer interface {
 	SetTicket(id string, t Ticket)
 	UseTicket(id string) (Ticket, bool)
-	DeleteOldTickets(maxDur time.Duration)
 }
 
-func DeleteOldTicketsLoop(ctx context.Context, r Storer, maxDur time.Duration) {
-	ticker := time.NewTicker(time.Second)
-	defer ticker.Stop()
+// ContextStorer is like Storer, but its methods can be cancelled and report failures of the underlying store.
+// UseTicket returns ErrTicketNotFound if there is no ticket to redeem for the given id, including expired ones.
+type ContextStorer interface {
+	SetTicket(ctx context.Context, id string, t Ticket) error
+	UseTicket(ctx context.Context, id string) (Ticket, error)
+}
+
+// AdaptStorer returns a ContextStorer backed by r.
+func AdaptStorer(r Storer) ContextStorer {
+	return storerAdapter{r: r}
+}
+
+type storerAdapter struct {
+	r Storer
+}
+
+func (a storerAdapter) SetTicket(ctx context.Context, id string, t Ticket) error {
+	err := ctx.Err()
+	if err != nil {
+		return err
+	}
+	a.r.SetTicket(id, t)
+	return nil
+}
+
+func (a storerAdapter) UseTicket(ctx context.Context, id string) (Ticket, error) {
+	err := ctx.Err()
+	if err != nil {
+		return Ticket{}, err
+	}
+	t, ok := a.r.UseTicket(id)
+	if !ok {
+		return Ticket{}, ErrTicketNotFound
+	}
+	return t, nil
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			r.DeleteOldTickets(maxDur)
-		case <-ctx.Done():
-			return
-		}
+func (a storerAdapter) Tickets(ctx context.Context) (map[string]Ticket, error) {
+	err := ctx.Err()
+	if err != nil {
+		return nil, err
+	}
+	l, ok := a.r.(Lister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	return l.Tickets(), nil
+}
+
+// Lister is implemented by storers that can list the tickets waiting to be redeemed, keyed by id.
+type Lister interface {
+	Tickets() map[string]Ticket
+}
+
+// ContextLister is like Lister, for ContextStorer.
+type ContextLister interface {
+	Tickets(ctx context.Context) (map[string]Ticket, error)
+}
+
+// ListTickets returns the tickets of r waiting to be redeemed, keyed by id, or ErrListNotSupported if r can't list
+// them.
+func ListTickets(ctx context.Context, r ContextStorer) (map[string]Ticket, error) {
+	l, ok := r.(ContextLister)
+	if !ok {
+		return nil, ErrListNotSupported
+	}
+	return l.Tickets(ctx)
+}
+
+// Issuer is implemented by storers that generate the ids of the tickets themselves.
+type Issuer interface {
+	IssueTicket(ctx context.Context, t Ticket) (id string, err error)
+}
+
+// IssueTicket stores t in r and returns the id the client must present to redeem it.
+func IssueTicket(ctx context.Context, r ContextStorer, t Ticket) (string, error) {
+	if issuer, ok := r.(Issuer); ok {
+		return issuer.IssueTicket(ctx, t)
+	}
+
+	id, err := uuid.NewV4()
+	if err != nil {
+		return "", err
+	}
+	err = r.SetTicket(ctx, id.String(), t)
+	if err != nil {
+		return "", err
 	}
+	return id.String(), nil
 }
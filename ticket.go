@@ -1,14 +1,57 @@
 package retroproxy
 
 import (
+	"fmt"
 	"time"
 )
 
+// DefaultTicketTTL is the lifetime of the tickets without their own expiry by default, counted from when they were
+// issued.
+const DefaultTicketTTL = 10 * time.Second
+
 type Ticket struct {
 	Host     string
 	Port     string
 	Original string
 
-	IssuedAt time.Time
-	ServerId int
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	ServerId  int
+
+	// ClientIP is the IP address of the client the ticket was issued to.
+	ClientIP string
+	// Account is the username of the account the ticket was issued to.
+	Account string
+	// Realm is the namespace the ticket was issued in, if any.
+	Realm string
+	// SourceIP is the local IP address the connection to the game server is made from, or an empty string for any.
+	SourceIP string
+	// GameAddr is the public address of the game proxy the ticket was issued for, which the other game proxies refuse.
+	GameAddr string
+}
+
+// Expired reports whether t can no longer be redeemed at now. Tickets without an expiry never expire.
+func (t Ticket) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+// withExpiry returns t expiring ttl after it was issued, or after now if that's unknown too, unless it has its own
+// expiry. It bounds the tickets set by callers that don't give them one.
+func (t Ticket) withExpiry(ttl time.Duration) Ticket {
+	if !t.ExpiresAt.IsZero() {
+		return t
+	}
+	issuedAt := t.IssuedAt
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	t.ExpiresAt = issuedAt.Add(ttl)
+	return t
+}
+
+// String formats t for logs, with its original ticket masked.
+func (t Ticket) String() string {
+	t.Original = RedactedMask
+	type ticket Ticket
+	return fmt.Sprintf("%+v", ticket(t))
 }